@@ -0,0 +1,171 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+)
+
+//every path of the API is under this one, a new version gets a new prefix
+const Prefix = "/api/v1"
+
+//request bodies larger than this are refused
+const maxBodySize = 1 << 20
+
+//a cell as the API reads and writes it
+type Cell struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Room  string `json:"room"`
+	//nil when writing a cell leaves its privacy, sources or tags as they are
+	Private *bool         `json:"private"`
+	Sources []string      `json:"sources"`
+	Tags    []string      `json:"tags"`
+	Links   []CellSummary `json:"links"`
+	Created time.Time     `json:"created"`
+	Updated time.Time     `json:"updated"`
+}
+
+//what lists of cells tell of each cell, the cell itself has the rest
+type CellSummary struct {
+	Id      string    `json:"id"`
+	Title   string    `json:"title"`
+	Room    string    `json:"room"`
+	Private bool      `json:"private"`
+	Updated time.Time `json:"updated"`
+}
+
+//a room and how many cells it has
+type Room struct {
+	Name    string `json:"name"`
+	Cells   int    `json:"cells"`
+	Private bool   `json:"private"`
+}
+
+//the body of every response that is not a success
+type Error struct {
+	Error string `json:"error"`
+}
+
+//CellOf returns the cell as the API reads and writes it
+func CellOf(cell models.Cell) Cell {
+	private := cell.Private
+	c := Cell{Id: cell.Id,
+		Title:   cell.Title,
+		Body:    cell.Body,
+		Room:    cell.Room,
+		Private: &private,
+		Sources: []string{},
+		Tags:    []string{},
+		Links:   Summarize(cell.Links),
+		Created: cell.Create_time,
+		Updated: cell.Update_time}
+	for _, source := range cell.Sources {
+		c.Sources = append(c.Sources, source.Source)
+	}
+	for _, tag := range cell.Tags {
+		c.Tags = append(c.Tags, tag.Tag)
+	}
+	return c
+}
+
+//Summarize returns what lists of cells tell of each of the cells
+func Summarize(cells []models.Cell) []CellSummary {
+	summaries := []CellSummary{}
+	for _, cell := range cells {
+		summaries = append(summaries, CellSummary{Id: cell.Id,
+			Title:   cell.Title,
+			Room:    cell.Room,
+			Private: cell.Private,
+			Updated: cell.Update_time})
+	}
+	return summaries
+}
+
+//RoomsOf returns the rooms as the API lists them
+func RoomsOf(collections []models.CollectionOfCells) []Room {
+	rooms := []Room{}
+	for _, collection := range collections {
+		rooms = append(rooms, Room{Name: collection.Name, Cells: collection.CellCount, Private: collection.Private})
+	}
+	return rooms
+}
+
+//an endpoint of the API, under Prefix
+type Endpoint struct {
+	//the operationId of the endpoint in openapi.json
+	Operation string
+	Method    string
+	Path      string
+	//the lowest role allowed to use it: an editor for the endpoints that
+	//change the labyrinth, an admin for deleting cells, as in the pages
+	Role    models.Role
+	Handler http.HandlerFunc
+}
+
+//Endpoints declares every endpoint of the API, served from the repository
+func Endpoints(lob repository.LobRepository) []Endpoint {
+	return []Endpoint{
+		{Operation: "listCells", Method: "GET", Path: "/cells", Handler: listCells(lob)},
+		{Operation: "createCell", Method: "POST", Path: "/cells", Role: models.RoleEditor, Handler: createCell(lob)},
+		{Operation: "getCell", Method: "GET", Path: "/cells/{id}", Handler: getCell(lob)},
+		{Operation: "updateCell", Method: "PUT", Path: "/cells/{id}", Role: models.RoleEditor, Handler: updateCell(lob)},
+		{Operation: "deleteCell", Method: "DELETE", Path: "/cells/{id}", Role: models.RoleAdmin, Handler: deleteCell(lob)},
+		{Operation: "linkCell", Method: "POST", Path: "/cells/{id}/links", Role: models.RoleEditor, Handler: linkCell(lob)},
+		{Operation: "unlinkCell", Method: "DELETE", Path: "/cells/{id}/links/{other}", Role: models.RoleEditor, Handler: unlinkCell(lob)},
+		{Operation: "addSource", Method: "POST", Path: "/cells/{id}/sources", Role: models.RoleEditor, Handler: addSource(lob)},
+		{Operation: "removeSource", Method: "DELETE", Path: "/cells/{id}/sources", Role: models.RoleEditor, Handler: removeSource(lob)},
+		{Operation: "listRooms", Method: "GET", Path: "/rooms", Handler: listRooms(lob)},
+		{Operation: "listCellsInRoom", Method: "GET", Path: "/rooms/{room}/cells", Handler: listCellsInRoom(lob)},
+		{Operation: "listSources", Method: "GET", Path: "/sources", Handler: listSources(lob)},
+	}
+}
+
+//DocumentHandler serves the OpenAPI document of the API from the file
+func DocumentHandler(file string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, file)
+	}
+}
+
+//answers with the value in JSON
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error when writing an API response: %s", err)
+	}
+}
+
+//answers with the status and an error body
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, Error{Error: message})
+}
+
+//answers for an error of the repository, a 404 when what was asked for does not exist
+func writeRepositoryError(w http.ResponseWriter, err error, doing string) {
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	log.Printf("Error when %s: %s", doing, err)
+	writeError(w, http.StatusInternalServerError, "Error when "+doing)
+}
+
+//reads the JSON body of the request into value, answering a 400 if it cannot
+func readJSON(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		writeError(w, http.StatusBadRequest, "The body is not valid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,461 @@
+package api_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dacero/labyrinth-of-babel/api"
+	"github.com/dacero/labyrinth-of-babel/client"
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/gorilla/mux"
+)
+
+func TestApi(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Api Suite")
+}
+
+var _ = Describe("API", func() {
+	var (
+		lob    *memoryRepository
+		router *mux.Router
+		rr     *httptest.ResponseRecorder
+	)
+	serve := func(method string, path string, body interface{}) {
+		var reader *bytes.Reader
+		if s, ok := body.(string); ok {
+			reader = bytes.NewReader([]byte(s))
+		} else {
+			encoded, err := json.Marshal(body)
+			Expect(err).To(BeNil())
+			reader = bytes.NewReader(encoded)
+		}
+		req := httptest.NewRequest(method, api.Prefix+path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+	}
+	decode := func(value interface{}) {
+		Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(json.Unmarshal(rr.Body.Bytes(), value)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		lob = newMemoryRepository()
+		lob.NewCell(models.Cell{Title: "The Aleph", Body: "A point containing all points", Room: "Borges",
+			Sources: []models.Source{{Source: "El Aleph"}}})
+		lob.NewCell(models.Cell{Title: "Funes", Body: "Remembers everything", Room: "Borges"})
+		router = mux.NewRouter()
+		for _, endpoint := range api.Endpoints(lob) {
+			router.HandleFunc(api.Prefix+endpoint.Path, endpoint.Handler).Methods(endpoint.Method)
+		}
+	})
+
+	Describe("Reading cells", func() {
+		It("should return the cell with its sources and links", func() {
+			serve("GET", "/cells/1", nil)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var cell api.Cell
+			decode(&cell)
+			Expect(cell.Title).To(Equal("The Aleph"))
+			Expect(cell.Sources).To(Equal([]string{"El Aleph"}))
+			Expect(cell.Links).To(BeEmpty())
+		})
+		It("should answer 404 with an error body for cells that do not exist", func() {
+			serve("GET", "/cells/404", nil)
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+			var body api.Error
+			decode(&body)
+			Expect(body.Error).NotTo(BeEmpty())
+		})
+		It("should search cells", func() {
+			serve("GET", "/cells?q=everything", nil)
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var cells []api.CellSummary
+			decode(&cells)
+			Expect(len(cells)).To(Equal(1))
+			Expect(cells[0].Title).To(Equal("Funes"))
+		})
+	})
+
+	Describe("Writing cells", func() {
+		It("should create a cell and tell where it is", func() {
+			serve("POST", "/cells", api.Cell{Title: "Tlön", Body: "Uqbar", Room: "Borges", Tags: []string{"Fiction"}})
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			var cell api.Cell
+			decode(&cell)
+			Expect(rr.Header().Get("Location")).To(Equal(api.Prefix + "/cells/" + cell.Id))
+			Expect(cell.Tags).To(Equal([]string{"Fiction"}))
+		})
+		It("should refuse cells without a body", func() {
+			serve("POST", "/cells", api.Cell{Title: "Empty", Room: "Borges"})
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+		It("should refuse bodies that are not JSON", func() {
+			serve("POST", "/cells", "title=Tlön")
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			var body api.Error
+			decode(&body)
+			Expect(body.Error).To(HavePrefix("The body is not valid JSON"))
+		})
+		It("should replace the sources sent with a cell", func() {
+			serve("PUT", "/cells/1", api.Cell{Title: "The Aleph", Body: "Every point", Room: "Borges", Sources: []string{"Borges"}})
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var cell api.Cell
+			decode(&cell)
+			Expect(cell.Body).To(Equal("Every point"))
+			Expect(cell.Sources).To(Equal([]string{"Borges"}))
+		})
+		It("should keep the sources when none are sent", func() {
+			serve("PUT", "/cells/1", api.Cell{Title: "The Aleph", Body: "Every point", Room: "Borges"})
+			var cell api.Cell
+			decode(&cell)
+			Expect(cell.Sources).To(Equal([]string{"El Aleph"}))
+		})
+		It("should keep the privacy when it is not sent", func() {
+			lob.cells["1"].Private = true
+			serve("PUT", "/cells/1", api.Cell{Title: "The Aleph", Body: "Every point", Room: "Borges"})
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(lob.cells["1"].Private).To(BeTrue())
+			public := false
+			serve("PUT", "/cells/1", api.Cell{Title: "The Aleph", Body: "Every point", Room: "Borges", Private: &public})
+			var cell api.Cell
+			decode(&cell)
+			Expect(*cell.Private).To(BeFalse())
+		})
+		It("should remove sources that are addresses", func() {
+			serve("POST", "/cells/1/sources", map[string]string{"source": "https://example.com/borges/aleph"})
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			serve("DELETE", "/cells/1/sources?source="+url.QueryEscape("https://example.com/borges/aleph"), nil)
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+			Expect(lob.cells["1"].Sources).To(Equal([]models.Source{{Source: "El Aleph"}}))
+		})
+		It("should refuse removing a source without saying which", func() {
+			serve("DELETE", "/cells/1/sources", nil)
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+		It("should delete a cell", func() {
+			serve("DELETE", "/cells/2", nil)
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+			serve("GET", "/cells/2", nil)
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("Linking cells", func() {
+		It("should link two cells once", func() {
+			serve("POST", "/cells/1/links", map[string]string{"id": "2"})
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			var cell api.Cell
+			decode(&cell)
+			Expect(cell.Links[0].Id).To(Equal("2"))
+			serve("POST", "/cells/2/links", map[string]string{"id": "1"})
+			Expect(rr.Code).To(Equal(http.StatusConflict))
+		})
+		It("should unlink them", func() {
+			serve("POST", "/cells/1/links", map[string]string{"id": "2"})
+			serve("DELETE", "/cells/2/links/1", nil)
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+			serve("DELETE", "/cells/2/links/1", nil)
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+		It("should not link cells that do not exist", func() {
+			serve("POST", "/cells/1/links", map[string]string{"id": "404"})
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("Listing rooms and sources", func() {
+		It("should count the cells of each room", func() {
+			serve("GET", "/rooms", nil)
+			var rooms []api.Room
+			decode(&rooms)
+			Expect(rooms).To(Equal([]api.Room{{Name: "Borges", Cells: 2}}))
+		})
+		It("should list the cells of a room", func() {
+			serve("GET", "/rooms/Borges/cells", nil)
+			var cells []api.CellSummary
+			decode(&cells)
+			Expect(len(cells)).To(Equal(2))
+			serve("GET", "/rooms/Nowhere/cells", nil)
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+		It("should list the sources", func() {
+			serve("POST", "/cells/2/sources", map[string]string{"source": "Ficciones"})
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			serve("GET", "/sources", nil)
+			var sources []string
+			decode(&sources)
+			Expect(sources).To(Equal([]string{"El Aleph", "Ficciones"}))
+		})
+	})
+})
+
+var _ = Describe("OpenAPI document", func() {
+	type operation struct {
+		OperationId string                `json:"operationId"`
+		Security    []map[string][]string `json:"security"`
+	}
+	var document struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+
+	BeforeEach(func() {
+		spec, err := ioutil.ReadFile("openapi.json")
+		Expect(err).To(BeNil())
+		Expect(json.Unmarshal(spec, &document)).To(Succeed())
+	})
+
+	It("should describe every endpoint, and only them", func() {
+		described := make(map[string]operation)
+		for path, item := range document.Paths {
+			for method, raw := range item {
+				if method == "parameters" {
+					continue
+				}
+				var op operation
+				Expect(json.Unmarshal(raw, &op)).To(Succeed())
+				described[strings.ToUpper(method)+" "+path] = op
+			}
+		}
+		endpoints := api.Endpoints(newMemoryRepository())
+		Expect(len(described)).To(Equal(len(endpoints)))
+		for _, endpoint := range endpoints {
+			op, ok := described[endpoint.Method+" "+endpoint.Path]
+			Expect(ok).To(BeTrue(), endpoint.Method+" "+endpoint.Path)
+			Expect(op.OperationId).To(Equal(endpoint.Operation))
+			if endpoint.Role != models.RoleAnyone {
+				Expect(op.Security).To(Equal([]map[string][]string{{"bearerAuth": {}}}), endpoint.Operation)
+			} else {
+				Expect(op.Security).To(ContainElement(map[string][]string{}), endpoint.Operation)
+			}
+		}
+	})
+
+	It("should describe the fields the API reads and writes", func() {
+		for name, value := range map[string]interface{}{"Cell": api.Cell{}, "CellSummary": api.CellSummary{}, "Room": api.Room{}, "Error": api.Error{}} {
+			var fields []string
+			t := reflect.TypeOf(value)
+			for i := 0; i < t.NumField(); i++ {
+				fields = append(fields, t.Field(i).Tag.Get("json"))
+			}
+			var properties []string
+			for property := range document.Components.Schemas[name].Properties {
+				properties = append(properties, property)
+			}
+			Expect(properties).To(ConsistOf(fields), name)
+		}
+	})
+
+	Describe("Using the generated client", func() {
+		var (
+			lob    *memoryRepository
+			server *httptest.Server
+			lobby  *client.Client
+		)
+		BeforeEach(func() {
+			lob = newMemoryRepository()
+			lob.NewCell(models.Cell{Title: "The Aleph", Body: "A point containing all points", Room: "Borges"})
+			router := mux.NewRouter()
+			for _, endpoint := range api.Endpoints(lob) {
+				router.HandleFunc(api.Prefix+endpoint.Path, endpoint.Handler).Methods(endpoint.Method)
+			}
+			server = httptest.NewServer(router)
+			lobby = client.New(server.URL, "")
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should create, link and read cells", func() {
+			cell, err := lobby.CreateCell(client.Cell{Title: "Funes", Body: "Remembers everything", Room: "Borges", Sources: []string{"Ficciones"}})
+			Expect(err).To(BeNil())
+			Expect(cell.Sources).To(Equal([]string{"Ficciones"}))
+			_, err = lobby.LinkCell(cell.Id, client.Link{Id: "1"})
+			Expect(err).To(BeNil())
+			aleph, err := lobby.GetCell("1")
+			Expect(err).To(BeNil())
+			Expect(aleph.Links[0].Title).To(Equal("Funes"))
+			cells, err := lobby.ListCells("everything", nil, "Borges")
+			Expect(err).To(BeNil())
+			Expect(cells[0].Id).To(Equal(cell.Id))
+		})
+		It("should escape what goes in the path and the query", func() {
+			for _, source := range []string{"El Aleph? 1949", "https://example.com/borges/aleph?page=1"} {
+				_, err := lobby.AddSource("1", client.Source{Source: source})
+				Expect(err).To(BeNil())
+				Expect(lobby.RemoveSource("1", source)).To(Succeed())
+			}
+			cell, err := lobby.GetCell("1")
+			Expect(err).To(BeNil())
+			Expect(cell.Sources).To(BeEmpty())
+		})
+		It("should return what the API answered when it fails", func() {
+			_, err := lobby.GetCell("404")
+			statusError, ok := err.(*client.StatusError)
+			Expect(ok).To(BeTrue())
+			Expect(statusError.Status).To(Equal(http.StatusNotFound))
+			Expect(statusError.Message).To(Equal("Not found"))
+		})
+	})
+})
+
+//a labyrinth kept in memory, showing the API works with any repository
+type memoryRepository struct {
+	repository.LobRepository
+	cells map[string]*models.Cell
+	links map[[2]string]bool
+	next  int
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{cells: make(map[string]*models.Cell), links: make(map[[2]string]bool)}
+}
+
+func (m *memoryRepository) ordered() []models.Cell {
+	var cells []models.Cell
+	for _, cell := range m.cells {
+		cells = append(cells, *cell)
+	}
+	sort.Slice(cells, func(i, j int) bool { return cells[i].Id < cells[j].Id })
+	return cells
+}
+
+func (m *memoryRepository) GetCell(id string) (models.Cell, error) {
+	cell, ok := m.cells[id]
+	if !ok {
+		return models.Cell{}, sql.ErrNoRows
+	}
+	found := *cell
+	found.Links = nil
+	for _, other := range m.ordered() {
+		if linked, _ := m.CheckLink(id, other.Id); linked {
+			found.Links = append(found.Links, other)
+		}
+	}
+	return found, nil
+}
+
+func (m *memoryRepository) NewCell(cell models.Cell) (string, error) {
+	m.next++
+	cell.Id = strconv.Itoa(m.next)
+	m.cells[cell.Id] = &cell
+	return cell.Id, nil
+}
+
+func (m *memoryRepository) UpdateCell(cell models.Cell) (int64, error) {
+	current, ok := m.cells[cell.Id]
+	if !ok {
+		return 0, nil
+	}
+	current.Title, current.Body, current.Room, current.Private = cell.Title, cell.Body, cell.Room, cell.Private
+	return 1, nil
+}
+
+func (m *memoryRepository) DeleteCell(id string) error {
+	if _, ok := m.cells[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.cells, id)
+	return nil
+}
+
+func (m *memoryRepository) AddSourceToCell(id string, source models.Source) (models.Cell, error) {
+	m.cells[id].Sources = append(m.cells[id].Sources, source)
+	return m.GetCell(id)
+}
+
+func (m *memoryRepository) RemoveSourceFromCell(id string, source models.Source) (models.Cell, error) {
+	cell := m.cells[id]
+	kept := []models.Source{}
+	for _, s := range cell.Sources {
+		if s != source {
+			kept = append(kept, s)
+		}
+	}
+	cell.Sources = kept
+	return m.GetCell(id)
+}
+
+func (m *memoryRepository) AddTagToCell(id string, tag models.Tag) (models.Cell, error) {
+	m.cells[id].Tags = append(m.cells[id].Tags, tag)
+	return m.GetCell(id)
+}
+
+func (m *memoryRepository) CheckLink(idA string, idB string) (bool, error) {
+	return m.links[[2]string{idA, idB}] || m.links[[2]string{idB, idA}], nil
+}
+
+func (m *memoryRepository) LinkCells(idA string, idB string) error {
+	m.links[[2]string{idA, idB}] = true
+	return nil
+}
+
+func (m *memoryRepository) UnlinkCells(idA string, idB string) error {
+	delete(m.links, [2]string{idA, idB})
+	delete(m.links, [2]string{idB, idA})
+	return nil
+}
+
+func (m *memoryRepository) SearchCellsWithTags(term string, tags []string) []models.Cell {
+	var cells []models.Cell
+	for _, cell := range m.ordered() {
+		if strings.Contains(cell.Title, term) || strings.Contains(cell.Body, term) {
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+func (m *memoryRepository) ListRooms() ([]models.CollectionOfCells, error) {
+	counts := make(map[string]int)
+	for _, cell := range m.cells {
+		counts[cell.Room]++
+	}
+	var rooms []models.CollectionOfCells
+	for room, count := range counts {
+		rooms = append(rooms, models.CollectionOfCells{Name: room, CellCount: count})
+	}
+	return rooms, nil
+}
+
+func (m *memoryRepository) ListCellsInRoom(room string) ([]models.Cell, error) {
+	var cells []models.Cell
+	for _, cell := range m.ordered() {
+		if cell.Room == room {
+			cells = append(cells, cell)
+		}
+	}
+	return cells, nil
+}
+
+func (m *memoryRepository) SearchSources(term string) []models.Source {
+	var sources []models.Source
+	for _, cell := range m.ordered() {
+		for _, source := range cell.Sources {
+			if strings.Contains(source.Source, term) {
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/gorilla/mux"
+)
+
+//lists the cells containing ?q= with every ?tag=, in the ?room= if any
+func listCells(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		r.ParseForm()
+		cells := lob.SearchCellsWithTags(r.FormValue("q"), r.Form["tag"])
+		if room := r.FormValue("room"); room != "" {
+			inRoom := cells[:0]
+			for _, cell := range cells {
+				if cell.Room == room {
+					inRoom = append(inRoom, cell)
+				}
+			}
+			cells = inRoom
+		}
+		writeJSON(w, http.StatusOK, Summarize(cells))
+	}
+}
+
+//checks what a cell needs before writing it, answering a 400 if it is missing
+func validCell(w http.ResponseWriter, cell Cell) bool {
+	if strings.TrimSpace(cell.Body) == "" || strings.TrimSpace(cell.Room) == "" {
+		writeError(w, http.StatusBadRequest, "A cell needs a body and a room")
+		return false
+	}
+	return true
+}
+
+func createCell(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		var cell Cell
+		if !readJSON(w, r, &cell) || !validCell(w, cell) {
+			return
+		}
+		newCell := models.Cell{Title: cell.Title, Body: cell.Body, Room: cell.Room, Private: cell.Private != nil && *cell.Private}
+		for _, source := range cell.Sources {
+			newCell.Sources = append(newCell.Sources, models.Source{Source: source})
+		}
+		id, err := lob.NewCell(newCell)
+		if err != nil {
+			writeRepositoryError(w, err, "creating the cell")
+			return
+		}
+		for _, tag := range cell.Tags {
+			if _, err := lob.AddTagToCell(id, models.Tag{Tag: tag}); err != nil {
+				writeRepositoryError(w, err, "tagging the cell")
+				return
+			}
+		}
+		created, err := lob.GetCell(id)
+		if err != nil {
+			writeRepositoryError(w, err, "reading the new cell")
+			return
+		}
+		w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+id)
+		writeJSON(w, http.StatusCreated, CellOf(created))
+	}
+}
+
+func getCell(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		cell, err := lob.GetCell(mux.Vars(r)["id"])
+		if err != nil {
+			writeRepositoryError(w, err, "reading the cell")
+			return
+		}
+		writeJSON(w, http.StatusOK, CellOf(cell))
+	}
+}
+
+//replaces the title, body and room of the cell, and its privacy, sources
+//and tags when they are sent
+func updateCell(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		id := mux.Vars(r)["id"]
+		var cell Cell
+		if !readJSON(w, r, &cell) || !validCell(w, cell) {
+			return
+		}
+		current, err := lob.GetCell(id)
+		if err != nil {
+			writeRepositoryError(w, err, "reading the cell")
+			return
+		}
+		private := current.Private
+		if cell.Private != nil {
+			private = *cell.Private
+		}
+		_, err = lob.UpdateCell(models.Cell{Id: id, Title: cell.Title, Body: cell.Body, Room: cell.Room, Private: private})
+		if err != nil {
+			writeRepositoryError(w, err, "updating the cell")
+			return
+		}
+		if cell.Sources != nil {
+			var have []string
+			for _, source := range current.Sources {
+				have = append(have, source.Source)
+			}
+			err = replace(have, cell.Sources, func(source string) error {
+				_, err := lob.AddSourceToCell(id, models.Source{Source: source})
+				return err
+			}, func(source string) error {
+				_, err := lob.RemoveSourceFromCell(id, models.Source{Source: source})
+				return err
+			})
+			if err != nil {
+				writeRepositoryError(w, err, "updating the sources of the cell")
+				return
+			}
+		}
+		if cell.Tags != nil {
+			var have []string
+			for _, tag := range current.Tags {
+				have = append(have, tag.Tag)
+			}
+			err = replace(have, cell.Tags, func(tag string) error {
+				_, err := lob.AddTagToCell(id, models.Tag{Tag: tag})
+				return err
+			}, func(tag string) error {
+				_, err := lob.RemoveTagFromCell(id, models.Tag{Tag: tag})
+				return err
+			})
+			if err != nil {
+				writeRepositoryError(w, err, "updating the tags of the cell")
+				return
+			}
+		}
+		updated, err := lob.GetCell(id)
+		if err != nil {
+			writeRepositoryError(w, err, "reading the cell")
+			return
+		}
+		writeJSON(w, http.StatusOK, CellOf(updated))
+	}
+}
+
+//adds the values of want missing from have and removes those of have not in want
+func replace(have []string, want []string, add func(string) error, remove func(string) error) error {
+	wanted := make(map[string]bool, len(want))
+	for _, value := range want {
+		if value = strings.TrimSpace(value); value != "" {
+			wanted[value] = true
+		}
+	}
+	for _, value := range have {
+		if wanted[value] {
+			delete(wanted, value)
+			continue
+		}
+		if err := remove(value); err != nil {
+			return err
+		}
+	}
+	for value := range wanted {
+		if err := add(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteCell(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		if err := lob.DeleteCell(mux.Vars(r)["id"]); err != nil {
+			writeRepositoryError(w, err, "deleting the cell")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+//links the cell with the one whose id is posted as {"id": "..."}
+func linkCell(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		id := mux.Vars(r)["id"]
+		var other struct {
+			Id string `json:"id"`
+		}
+		if !readJSON(w, r, &other) {
+			return
+		}
+		if other.Id == "" || other.Id == id {
+			writeError(w, http.StatusBadRequest, "A cell links to another cell")
+			return
+		}
+		for _, cellId := range []string{id, other.Id} {
+			if _, err := lob.GetCell(cellId); err != nil {
+				writeRepositoryError(w, err, "reading the cell")
+				return
+			}
+		}
+		linked, err := lob.CheckLink(id, other.Id)
+		if err != nil {
+			writeRepositoryError(w, err, "linking the cells")
+			return
+		}
+		if linked {
+			writeError(w, http.StatusConflict, "The cells are already linked")
+			return
+		}
+		if err = lob.LinkCells(id, other.Id); err != nil {
+			writeRepositoryError(w, err, "linking the cells")
+			return
+		}
+		cell, err := lob.GetCell(id)
+		if err != nil {
+			writeRepositoryError(w, err, "reading the cell")
+			return
+		}
+		writeJSON(w, http.StatusCreated, CellOf(cell))
+	}
+}
+
+func unlinkCell(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		id, other := mux.Vars(r)["id"], mux.Vars(r)["other"]
+		linked, err := lob.CheckLink(id, other)
+		if err != nil {
+			writeRepositoryError(w, err, "unlinking the cells")
+			return
+		}
+		if !linked {
+			writeError(w, http.StatusNotFound, "The cells are not linked")
+			return
+		}
+		if err = lob.UnlinkCells(id, other); err != nil {
+			writeRepositoryError(w, err, "unlinking the cells")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+//adds the source posted as {"source": "..."} to the cell
+func addSource(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		id := mux.Vars(r)["id"]
+		var source struct {
+			Source string `json:"source"`
+		}
+		if !readJSON(w, r, &source) {
+			return
+		}
+		if strings.TrimSpace(source.Source) == "" {
+			writeError(w, http.StatusBadRequest, "Empty source")
+			return
+		}
+		if _, err := lob.GetCell(id); err != nil {
+			writeRepositoryError(w, err, "reading the cell")
+			return
+		}
+		cell, err := lob.AddSourceToCell(id, models.Source{Source: source.Source})
+		if err != nil {
+			writeRepositoryError(w, err, "adding the source")
+			return
+		}
+		writeJSON(w, http.StatusCreated, CellOf(cell))
+	}
+}
+
+func removeSource(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		id, source := mux.Vars(r)["id"], r.URL.Query().Get("source")
+		if source == "" {
+			writeError(w, http.StatusBadRequest, "Missing the source to remove")
+			return
+		}
+		cell, err := lob.GetCell(id)
+		if err != nil {
+			writeRepositoryError(w, err, "reading the cell")
+			return
+		}
+		found := false
+		for _, s := range cell.Sources {
+			found = found || s.Source == source
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "The cell does not have that source")
+			return
+		}
+		if _, err = lob.RemoveSourceFromCell(id, models.Source{Source: source}); err != nil {
+			writeRepositoryError(w, err, "removing the source")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
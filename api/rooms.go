@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/gorilla/mux"
+)
+
+func listRooms(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		collections, err := lob.ListRooms()
+		if err != nil {
+			writeRepositoryError(w, err, "listing the rooms")
+			return
+		}
+		writeJSON(w, http.StatusOK, RoomsOf(collections))
+	}
+}
+
+func listCellsInRoom(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		cells, err := lob.ListCellsInRoom(mux.Vars(r)["room"])
+		if err != nil {
+			writeRepositoryError(w, err, "listing the cells of the room")
+			return
+		}
+		if len(cells) == 0 {
+			writeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, Summarize(cells))
+	}
+}
+
+//lists the sources containing ?q=, every one without it
+func listSources(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		sources := []string{}
+		for _, source := range lob.SearchSources(r.FormValue("q")) {
+			sources = append(sources, source.Source)
+		}
+		writeJSON(w, http.StatusOK, sources)
+	}
+}
@@ -0,0 +1,90 @@
+//Package client talks to the API of a labyrinth, so that tools change it
+//without posting forms; the methods and types in client_gen.go are
+//generated from api/openapi.json
+package client
+
+//go:generate go run gen.go
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//Client calls the API of the labyrinth served at BaseURL
+type Client struct {
+	//where the labyrinth is served, such as https://example.com/team
+	BaseURL string
+	//an API token of the user, visitors only read public cells
+	Token      string
+	HTTPClient *http.Client
+}
+
+//New returns a client of the labyrinth served at baseURL, calling it with the token
+func New(baseURL string, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+//StatusError is what the API answered when it did not do what was asked
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+//calls the API, sending body and reading the answer into result when they are not nil
+func (c *Client) do(method string, path string, query url.Values, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	target := c.BaseURL + apiPath + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		var answer Error
+		if err := json.NewDecoder(res.Body).Decode(&answer); err != nil || answer.Error == "" {
+			answer.Error = http.StatusText(res.StatusCode)
+		}
+		return &StatusError{Status: res.StatusCode, Message: answer.Error}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
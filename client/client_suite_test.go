@@ -0,0 +1,32 @@
+package client_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dacero/labyrinth-of-babel/client/internal/generate"
+)
+
+func TestClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Client Suite")
+}
+
+var _ = Describe("Client", func() {
+	It("should be generated from the OpenAPI document of the API", func() {
+		spec, err := ioutil.ReadFile("../api/openapi.json")
+		Expect(err).To(BeNil())
+		generated, err := generate.Client(spec, "client")
+		Expect(err).To(BeNil())
+		current, err := ioutil.ReadFile("client_gen.go")
+		Expect(err).To(BeNil())
+		Expect(string(current)).To(Equal(string(generated)), "run go generate ./client")
+	})
+	It("should refuse documents it cannot generate", func() {
+		_, err := generate.Client([]byte(`{"servers": [{"url": "v1"}], "paths": {}, "components": {"schemas": {"Cell": {"properties": {"id": {"type": "object"}}}}}}`), "client")
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,26 @@
+//go:build ignore
+// +build ignore
+
+//gen writes client_gen.go from the OpenAPI document of the API
+package main
+
+import (
+	"io/ioutil"
+	"log"
+
+	"github.com/dacero/labyrinth-of-babel/client/internal/generate"
+)
+
+func main() {
+	spec, err := ioutil.ReadFile("../api/openapi.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	source, err := generate.Client(spec, "client")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("client_gen.go", source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,295 @@
+//Package generate writes the Go client of the API from its OpenAPI document
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"net/url"
+	"strings"
+)
+
+//where the document is served, its servers are relative to it
+const documentPath = "/api/openapi.json"
+
+type document struct {
+	Servers []struct {
+		Url string `json:"url"`
+	} `json:"servers"`
+	Paths      json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+type schema struct {
+	Ref         string          `json:"$ref"`
+	Type        string          `json:"type"`
+	Format      string          `json:"format"`
+	Nullable    bool            `json:"nullable"`
+	Description string          `json:"description"`
+	Items       *schema         `json:"items"`
+	Properties  json.RawMessage `json:"properties"`
+}
+
+type parameter struct {
+	Name   string `json:"name"`
+	In     string `json:"in"`
+	Schema schema `json:"schema"`
+}
+
+type content struct {
+	Content map[string]struct {
+		Schema schema `json:"schema"`
+	} `json:"content"`
+}
+
+type operation struct {
+	OperationId string             `json:"operationId"`
+	Summary     string             `json:"summary"`
+	Parameters  []parameter        `json:"parameters"`
+	RequestBody *content           `json:"requestBody"`
+	Responses   map[string]content `json:"responses"`
+}
+
+var methods = []string{"get", "put", "post", "delete", "patch"}
+
+//Client returns the source of the client in the package, with a type for
+//every schema of the document and a method for every operation
+func Client(spec []byte, pkg string) ([]byte, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Servers) == 0 {
+		return nil, fmt.Errorf("The document has no servers")
+	}
+	base, err := url.Parse(documentPath)
+	if err != nil {
+		return nil, err
+	}
+	server, err := url.Parse(doc.Servers[0].Url)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "//every path of the API is under this one\nconst apiPath = %q\n\n", base.ResolveReference(server).Path)
+
+	names, err := keys(doc.Components.Schemas)
+	if err != nil {
+		return nil, err
+	}
+	var schemas map[string]schema
+	if err := json.Unmarshal(doc.Components.Schemas, &schemas); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := writeType(&out, name, schemas[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	paths, err := keys(doc.Paths)
+	if err != nil {
+		return nil, err
+	}
+	var items map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(doc.Paths, &items); err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		var shared []parameter
+		if raw, ok := items[path]["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, err
+			}
+		}
+		for _, method := range methods {
+			raw, ok := items[path][method]
+			if !ok {
+				continue
+			}
+			var op operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, err
+			}
+			op.Parameters = append(append([]parameter{}, shared...), op.Parameters...)
+			if err := writeMethod(&out, strings.ToUpper(method), path, op); err != nil {
+				return nil, fmt.Errorf("%s %s: %s", method, path, err)
+			}
+		}
+	}
+	imports := `"net/url"`
+	if bytes.Contains(out.Bytes(), []byte("time.Time")) {
+		imports += "\n\"time\""
+	}
+	header := fmt.Sprintf("//Code generated by gen.go from api/openapi.json; DO NOT EDIT.\n\npackage %s\n\nimport (\n%s\n)\n\n", pkg, imports)
+	return format.Source(append([]byte(header), out.Bytes()...))
+}
+
+//the keys of a JSON object in the order they are written
+func keys(raw json.RawMessage) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	var names []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, token.(string))
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+//the text as the rest of a comment, Lists the cells is lists the cells
+func lowerFirst(text string) string {
+	return strings.ToLower(text[:1]) + text[1:]
+}
+
+//the Go name of an identifier of the document, listCells is ListCells
+func exported(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+//the Go type of values of the schema
+func goType(s schema) (string, error) {
+	if s.Ref != "" {
+		return strings.TrimPrefix(s.Ref, "#/components/schemas/"), nil
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "boolean":
+		return "bool", nil
+	case "integer":
+		return "int", nil
+	case "array":
+		if s.Items == nil {
+			return "", fmt.Errorf("An array without items")
+		}
+		item, err := goType(*s.Items)
+		return "[]" + item, err
+	}
+	return "", fmt.Errorf("No Go type for %q", s.Type)
+}
+
+func writeType(out *bytes.Buffer, name string, s schema) error {
+	properties, err := keys(s.Properties)
+	if err != nil {
+		return err
+	}
+	var fields map[string]schema
+	if err := json.Unmarshal(s.Properties, &fields); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "type %s struct {\n", name)
+	for _, property := range properties {
+		field := fields[property]
+		t, err := goType(field)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %s", name, property, err)
+		}
+		//nil tells a value that was left out, slices have their own nil
+		if field.Nullable && !strings.HasPrefix(t, "[]") {
+			t = "*" + t
+		}
+		if field.Description != "" {
+			fmt.Fprintf(out, "//%s\n", lowerFirst(field.Description))
+		}
+		fmt.Fprintf(out, "%s %s `json:\"%s\"`\n", exported(property), t, property)
+	}
+	fmt.Fprintf(out, "}\n\n")
+	return nil
+}
+
+func writeMethod(out *bytes.Buffer, method string, path string, op operation) error {
+	var args, query []string
+	for _, p := range op.Parameters {
+		t, err := goType(p.Schema)
+		if err != nil {
+			return err
+		}
+		args = append(args, p.Name+" "+t)
+		switch {
+		case p.In != "query":
+		case t == "[]string":
+			query = append(query, fmt.Sprintf("query[%q] = %s\n", p.Name, p.Name))
+		case t == "string":
+			query = append(query, fmt.Sprintf("if %s != \"\" {\nquery.Set(%q, %s)\n}\n", p.Name, p.Name, p.Name))
+		default:
+			return fmt.Errorf("No query parameters of type %s", t)
+		}
+	}
+	body := "nil"
+	if op.RequestBody != nil {
+		t, err := goType(op.RequestBody.Content["application/json"].Schema)
+		if err != nil {
+			return err
+		}
+		args = append(args, "body "+t)
+		body = "body"
+	}
+	result := ""
+	for status, response := range op.Responses {
+		if strings.HasPrefix(status, "2") {
+			if c, ok := response.Content["application/json"]; ok {
+				t, err := goType(c.Schema)
+				if err != nil {
+					return err
+				}
+				result = t
+			}
+		}
+	}
+
+	name := exported(op.OperationId)
+	fmt.Fprintf(out, "//%s %s\n", name, lowerFirst(op.Summary))
+	if result == "" {
+		fmt.Fprintf(out, "func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+	} else {
+		fmt.Fprintf(out, "func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), result)
+	}
+	values := "nil"
+	if len(query) > 0 {
+		fmt.Fprintf(out, "query := url.Values{}\n%s", strings.Join(query, ""))
+		values = "query"
+	}
+	if result == "" {
+		fmt.Fprintf(out, "return c.do(%q, %s, %s, %s, nil)\n}\n\n", method, pathExpression(path), values, body)
+		return nil
+	}
+	fmt.Fprintf(out, "var result %s\n", result)
+	fmt.Fprintf(out, "err := c.do(%q, %s, %s, %s, &result)\n", method, pathExpression(path), values, body)
+	fmt.Fprintf(out, "return result, err\n}\n\n")
+	return nil
+}
+
+//the Go expression building the path, escaping its parameters
+func pathExpression(path string) string {
+	var parts []string
+	for path != "" {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			parts = append(parts, fmt.Sprintf("%q", path))
+			break
+		}
+		end := strings.Index(path, "}")
+		if start > 0 {
+			parts = append(parts, fmt.Sprintf("%q", path[:start]))
+		}
+		parts = append(parts, "url.PathEscape("+path[start+1:end]+")")
+		path = path[end+1:]
+	}
+	return strings.Join(parts, "+")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dacero/labyrinth-of-babel/graph"
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/dacero/labyrinth-of-babel/vault"
+)
+
+//runs the command passed as arguments instead of starting the server
+func runCommand(lob repository.LobRepository, args []string) error {
+	switch args[0] {
+	case "orphans":
+		return orphansCommand(lob, args[1:], os.Stdout)
+	case "export":
+		return exportCommand(lob, args[1:], os.Stdout)
+	case "export-vault":
+		return exportVaultCommand(lob, args[1:], os.Stdout)
+	case "import-vault":
+		return importVaultCommand(lob, args[1:], os.Stdout)
+	case "create-admin":
+		return createAdminCommand(lob, args[1:], os.Stdin, os.Stdout)
+	default:
+		return fmt.Errorf("Unknown command: %s", args[0])
+	}
+}
+
+//lists cells without links, dead ends and cells without sources
+func orphansCommand(lob repository.LobRepository, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("orphans", flag.ContinueOnError)
+	room := flags.String("room", "", "only report cells in this room")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := lob.ListOrphanCells(*room)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	printCells(w, "Without links", report.NoLinks)
+	printCells(w, "Dead ends", report.SingleLink)
+	printCells(w, "Without sources", report.NoSources)
+	return w.Flush()
+}
+
+func printCells(w io.Writer, header string, cells []models.Cell) {
+	fmt.Fprintf(w, "%s (%d)\n", header, len(cells))
+	for _, cell := range cells {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", cell.Id, cell.Room, cell.Summary())
+	}
+	fmt.Fprintln(w)
+}
+
+//writes the graph of cells and links in DOT, GraphML or GEXF
+func exportCommand(lob repository.LobRepository, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := flags.String("format", "dot", "dot, graphml or gexf")
+	room := flags.String("room", "", "only export cells in this room")
+	cellId := flags.String("cell", "", "only export the neighbourhood of this cell")
+	depth := flags.Int("depth", 2, "number of links away from -cell to export")
+	output := flags.String("o", "", "file to write to instead of the standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	format, ok := graph.Formats[*formatName]
+	if !ok {
+		return fmt.Errorf("Unknown export format: %s", *formatName)
+	}
+	analysis, err := graph.NewAnalyzer(lob).Analysis()
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	return format.Write(out, analysis.Graph.Restrict(*room, *cellId, *depth))
+}
+
+//writes every cell as a Markdown note of a zipped vault
+func exportVaultCommand(lob repository.LobRepository, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export-vault", flag.ContinueOnError)
+	output := flags.String("o", "", "file to write to instead of the standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	return vault.Export(lob, out)
+}
+
+//creates or updates a cell for every note of a vault, a folder or a zip
+func importVaultCommand(lob repository.LobRepository, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import-vault", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report what the import would do")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("Usage: import-vault [-dry-run] <folder or zip>")
+	}
+
+	files, err := vault.Read(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	report, err := vault.Import(lob, files, *dryRun)
+	if *dryRun {
+		fmt.Fprintln(out, "Dry run, nothing was changed")
+		fmt.Fprintln(out)
+	}
+	printNotes(out, "Created", report.Created)
+	printNotes(out, "Updated", report.Updated)
+	printNotes(out, "Unchanged", report.Unchanged)
+	printNotes(out, "Found by title", report.ByTitle)
+	printNotes(out, "Skipped", report.Skipped)
+	printNotes(out, "Unresolved wikilinks", report.Unresolved)
+	fmt.Fprintf(out, "Links (%d)\n", report.Links)
+	return err
+}
+
+func printNotes(w io.Writer, header string, notes []string) {
+	fmt.Fprintf(w, "%s (%d)\n", header, len(notes))
+	for _, note := range notes {
+		fmt.Fprintf(w, "  %s\n", note)
+	}
+	fmt.Fprintln(w)
+}
+
+//creates the first user of the labyrinth, reading its password from in so it
+//stays out of the shell history
+func createAdminCommand(lob repository.LobRepository, args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := flags.String("username", "admin", "name the admin logs in with")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Password for %s: ", *username)
+	password, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if len(password) < models.MinPasswordLength {
+		return fmt.Errorf("The password needs at least %d characters", models.MinPasswordLength)
+	}
+	if _, err := lob.GetUserByUsername(*username); err == nil {
+		return errors.New("There is already a user named " + *username)
+	}
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	id, err := lob.NewUser(models.User{Username: *username, PasswordHash: hash, Role: models.RoleAdmin})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nCreated %s with id %s\n", *username, id)
+	return nil
+}
@@ -0,0 +1,3 @@
+-- Links created from [[wiki links]] in the body of cells_a are flagged so
+-- they can be removed when the text no longer mentions them.
+ALTER TABLE `cells_links` ADD COLUMN `wiki` tinyint(1) NOT NULL DEFAULT 0 AFTER `cells_b`;
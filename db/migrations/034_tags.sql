@@ -0,0 +1,16 @@
+-- Tags let a cell belong to many collections besides its room.
+
+CREATE TABLE `tags` (
+  `tag` varchar(250) NOT NULL,
+  PRIMARY KEY (`tag`)
+);
+
+CREATE TABLE `cells_tags` (
+  `cells_id` varchar(40) NOT NULL,
+  `tags_tag` varchar(250) NOT NULL,
+  PRIMARY KEY (`cells_id`,`tags_tag`),
+  KEY `fk_cells_has_tags_tags1_idx` (`tags_tag`),
+  KEY `fk_cells_has_tags_cells1_idx` (`cells_id`),
+  CONSTRAINT `fk_cells_has_tags_cells1` FOREIGN KEY (`cells_id`) REFERENCES `cells` (`id`),
+  CONSTRAINT `fk_cells_has_tags_tags1` FOREIGN KEY (`tags_tag`) REFERENCES `tags` (`tag`)
+);
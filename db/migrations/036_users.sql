@@ -0,0 +1,10 @@
+-- Users log into the labyrinth with their own password instead of a shared secret.
+
+CREATE TABLE `users` (
+  `id` varchar(40) NOT NULL,
+  `username` varchar(250) NOT NULL,
+  `password_hash` varchar(100) NOT NULL,
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `username_UNIQUE` (`username`)
+);
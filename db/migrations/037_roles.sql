@@ -0,0 +1,5 @@
+-- Users are readers, editors or admins. Existing users keep editing the labyrinth.
+
+ALTER TABLE `users` ADD COLUMN `role` varchar(20) NOT NULL DEFAULT 'reader' AFTER `password_hash`;
+
+UPDATE `users` SET `role`='admin';
@@ -0,0 +1,5 @@
+-- Private cells, and every cell of a private room, are hidden from visitors who have not logged in.
+
+ALTER TABLE `rooms` ADD COLUMN `private` tinyint(1) NOT NULL DEFAULT 0;
+
+ALTER TABLE `cells` ADD COLUMN `private` tinyint(1) NOT NULL DEFAULT 0 AFTER `room`;
@@ -0,0 +1,15 @@
+-- Scripts act as a user with a long-lived token sent as Authorization: Bearer.
+
+CREATE TABLE `api_tokens` (
+  `id` varchar(40) NOT NULL,
+  `users_id` varchar(40) NOT NULL,
+  `name` varchar(250) NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `scopes` varchar(100) NOT NULL,
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `last_used_time` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `token_hash_UNIQUE` (`token_hash`),
+  KEY `fk_api_tokens_users1_idx` (`users_id`),
+  CONSTRAINT `fk_api_tokens_users1` FOREIGN KEY (`users_id`) REFERENCES `users` (`id`)
+);
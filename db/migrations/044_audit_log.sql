@@ -0,0 +1,21 @@
+-- Every change to the labyrinth is recorded with who made it and the values
+-- before and after, rows can be added but never changed or removed.
+
+CREATE TABLE `audit_log` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `users_id` varchar(40) DEFAULT NULL,
+  `username` varchar(250) DEFAULT NULL,
+  `action` varchar(40) NOT NULL,
+  `target` varchar(250) NOT NULL,
+  `before_value` json DEFAULT NULL,
+  `after_value` json DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `audit_log_target_idx` (`target`),
+  KEY `audit_log_username_idx` (`username`),
+  KEY `audit_log_create_time_idx` (`create_time`)
+);
+
+CREATE TRIGGER `audit_log_no_update` BEFORE UPDATE ON `audit_log` FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'The audit log is append-only';
+
+CREATE TRIGGER `audit_log_no_delete` BEFORE DELETE ON `audit_log` FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'The audit log is append-only';
@@ -7,6 +7,7 @@ USE `labyrinth_of_babel_test`;
 
 CREATE TABLE `rooms` (
   `room` varchar(250) NOT NULL,
+  `private` tinyint(1) NOT NULL DEFAULT 0,
   PRIMARY KEY (`room`)
 );
 
@@ -22,6 +23,7 @@ CREATE TABLE `cells` (
   `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
   `update_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
   `room` varchar(250) NOT NULL,
+  `private` tinyint(1) NOT NULL DEFAULT 0,
   PRIMARY KEY (`id`),
   KEY `fk_cells_rooms1_idx` (`room`),
   CONSTRAINT `fk_cells_rooms1` FOREIGN KEY (`room`) REFERENCES `rooms` (`room`)
@@ -37,22 +39,87 @@ CREATE TABLE `cells_sources` (
   CONSTRAINT `fk_cells_has_sources_sources1` FOREIGN KEY (`sources_source`) REFERENCES `sources` (`source`)
 );
 
+CREATE TABLE `tags` (
+  `tag` varchar(250) NOT NULL,
+  PRIMARY KEY (`tag`)
+);
+
+CREATE TABLE `cells_tags` (
+  `cells_id` varchar(40) NOT NULL,
+  `tags_tag` varchar(250) NOT NULL,
+  PRIMARY KEY (`cells_id`,`tags_tag`),
+  KEY `fk_cells_has_tags_tags1_idx` (`tags_tag`),
+  KEY `fk_cells_has_tags_cells1_idx` (`cells_id`),
+  CONSTRAINT `fk_cells_has_tags_cells1` FOREIGN KEY (`cells_id`) REFERENCES `cells` (`id`),
+  CONSTRAINT `fk_cells_has_tags_tags1` FOREIGN KEY (`tags_tag`) REFERENCES `tags` (`tag`)
+);
+
 CREATE TABLE `cells_links` (
   `cells_a` varchar(40) NOT NULL,
   `cells_b` varchar(40) NOT NULL,
+  `wiki` tinyint(1) NOT NULL DEFAULT 0,
   PRIMARY KEY (`cells_a`,`cells_b`),
   KEY `fk_cells_has_cells_cells2_idx` (`cells_b`),
   KEY `fk_cells_has_cells_cells1_idx` (`cells_a`),
   CONSTRAINT `fk_cells_has_cells_cells1` FOREIGN KEY (`cells_a`) REFERENCES `cells` (`id`),
   CONSTRAINT `fk_cells_has_cells_cells2` FOREIGN KEY (`cells_b`) REFERENCES `cells` (`id`)
 );
+
+CREATE TABLE `users` (
+  `id` varchar(40) NOT NULL,
+  `username` varchar(250) NOT NULL,
+  `password_hash` varchar(100) NOT NULL,
+  `role` varchar(20) NOT NULL DEFAULT 'reader',
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `username_UNIQUE` (`username`)
+);
+
+CREATE TABLE `api_tokens` (
+  `id` varchar(40) NOT NULL,
+  `users_id` varchar(40) NOT NULL,
+  `name` varchar(250) NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `scopes` varchar(100) NOT NULL,
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `last_used_time` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `token_hash_UNIQUE` (`token_hash`),
+  KEY `fk_api_tokens_users1_idx` (`users_id`),
+  CONSTRAINT `fk_api_tokens_users1` FOREIGN KEY (`users_id`) REFERENCES `users` (`id`)
+);
+
+CREATE TABLE `audit_log` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `users_id` varchar(40) DEFAULT NULL,
+  `username` varchar(250) DEFAULT NULL,
+  `action` varchar(40) NOT NULL,
+  `target` varchar(250) NOT NULL,
+  `before_value` json DEFAULT NULL,
+  `after_value` json DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `audit_log_target_idx` (`target`),
+  KEY `audit_log_username_idx` (`username`),
+  KEY `audit_log_create_time_idx` (`create_time`)
+);
+
+CREATE TRIGGER `audit_log_no_update` BEFORE UPDATE ON `audit_log` FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'The audit log is append-only';
+
+CREATE TRIGGER `audit_log_no_delete` BEFORE DELETE ON `audit_log` FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'The audit log is append-only';
   
-INSERT INTO `rooms` VALUES ('This is a room');
+INSERT INTO `rooms` (`room`) VALUES ('This is a room');
 
 INSERT INTO `sources` VALUES ('Analects'),('Confucius');
 
-INSERT INTO `cells` VALUES ('417ecfe7-d2b4-4e43-afd4-dbf5f431d97d','Idea two','The second idea has a shorter body, but it\'s good enough.','2021-02-20 07:54:18','2021-02-20 07:54:18','This is a room'),('72aed05b-cb2d-4cad-bf70-05d8ae02a7bc','Idea one','Body of the first idea. Lengthy, useless, but interesting','2021-02-20 07:53:08','2021-02-20 07:53:08','This is a room'),('df38bd04-0ec4-41bf-9e53-d0eeb95a4939','','The third idea has no title, so that we can test what happens here','2021-02-20 07:55:36','2021-02-20 07:55:36','This is a room');
+INSERT INTO `cells` (`id`, `title`, `body`, `create_time`, `update_time`, `room`) VALUES ('417ecfe7-d2b4-4e43-afd4-dbf5f431d97d','Idea two','The second idea has a shorter body, but it\'s good enough.','2021-02-20 07:54:18','2021-02-20 07:54:18','This is a room'),('72aed05b-cb2d-4cad-bf70-05d8ae02a7bc','Idea one','Body of the first idea. Lengthy, useless, but interesting','2021-02-20 07:53:08','2021-02-20 07:53:08','This is a room'),('df38bd04-0ec4-41bf-9e53-d0eeb95a4939','','The third idea has no title, so that we can test what happens here','2021-02-20 07:55:36','2021-02-20 07:55:36','This is a room');
 
 INSERT INTO `cells_sources` VALUES ('417ecfe7-d2b4-4e43-afd4-dbf5f431d97d','Confucius'),('72aed05b-cb2d-4cad-bf70-05d8ae02a7bc','Analects'),('72aed05b-cb2d-4cad-bf70-05d8ae02a7bc','Confucius'),('df38bd04-0ec4-41bf-9e53-d0eeb95a4939','Confucius');
 
-INSERT INTO `cells_links` VALUES ('72aed05b-cb2d-4cad-bf70-05d8ae02a7bc','417ecfe7-d2b4-4e43-afd4-dbf5f431d97d'),('df38bd04-0ec4-41bf-9e53-d0eeb95a4939','72aed05b-cb2d-4cad-bf70-05d8ae02a7bc');
+INSERT INTO `cells_links` (`cells_a`, `cells_b`) VALUES ('72aed05b-cb2d-4cad-bf70-05d8ae02a7bc','417ecfe7-d2b4-4e43-afd4-dbf5f431d97d'),('df38bd04-0ec4-41bf-9e53-d0eeb95a4939','72aed05b-cb2d-4cad-bf70-05d8ae02a7bc');
+
+INSERT INTO `tags` VALUES ('Borges'),('Ethics');
+
+INSERT INTO `cells_tags` VALUES ('72aed05b-cb2d-4cad-bf70-05d8ae02a7bc','Borges'),('72aed05b-cb2d-4cad-bf70-05d8ae02a7bc','Ethics'),('df38bd04-0ec4-41bf-9e53-d0eeb95a4939','Ethics');
+
+INSERT INTO `users` (`id`, `username`, `password_hash`, `role`) VALUES ('0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21','borges','$2a$10$9b/NOTvcsSVfH8wjL9kKzOkBa00ed.86WRP3K/aw1uT72ZLYQe3B6','editor'),('5d0c7e3b-2a1f-4c8e-b6d9-8f4a1e2c3b70','funes','$2a$10$9b/NOTvcsSVfH8wjL9kKzOkBa00ed.86WRP3K/aw1uT72ZLYQe3B6','reader'),('a9e4c2d1-7b3f-4e5a-8c6d-1f2e3a4b5c6d','ireneo','$2a$10$9b/NOTvcsSVfH8wjL9kKzOkBa00ed.86WRP3K/aw1uT72ZLYQe3B6','admin');
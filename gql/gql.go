@@ -0,0 +1,117 @@
+//Package gql serves the labyrinth as a GraphQL graph of cells, links, rooms
+//and sources, so that clients walk it in one request instead of many
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/dacero/labyrinth-of-babel/repository"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//request bodies larger than this are refused
+const maxBodySize = 1 << 20
+
+//queries nesting deeper than this are refused, cell → links → links → sources → name is 5
+const maxDepth = 5
+
+//queries resolving more cells and sources than this fail, as their breadth,
+//aliases included, grows with every level the depth allows
+const maxNodes = 1000
+
+const schemaString = `
+	schema {
+		query: Query
+	}
+
+	type Query {
+		cell(id: ID!): Cell
+		cellByTitle(title: String!): Cell
+		search(text: String!, tags: [String!]): [Cell!]!
+		rooms: [Room!]!
+		room(name: String!): Room
+		sources(search: String): [Source!]!
+	}
+
+	type Cell {
+		id: ID!
+		title: String!
+		body: String!
+		room: Room!
+		private: Boolean!
+		created: String!
+		updated: String!
+		sources: [Source!]!
+		tags: [String!]!
+		links: [Cell!]!
+	}
+
+	type Room {
+		name: String!
+		private: Boolean!
+		cellCount: Int!
+		cells: [Cell!]!
+	}
+
+	type Source {
+		name: String!
+		cells: [Cell!]!
+	}
+`
+
+//the schema is parsed once, the repository of each query travels in its context
+var schema = graphql.MustParseSchema(schemaString, &query{}, graphql.MaxDepth(maxDepth))
+
+type contextKey int
+
+const requestKey contextKey = iota
+
+//the query of a GraphQL request, read from the URL of a GET or the JSON body of a POST
+type params struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+//Handler answers GraphQL queries about the labyrinth in the repository
+func Handler(lob repository.LobRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		var p params
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&p); err != nil {
+				writeError(w, http.StatusBadRequest, "The body is not valid JSON: "+err.Error())
+				return
+			}
+		} else {
+			p.Query = r.FormValue("query")
+			p.OperationName = r.FormValue("operationName")
+			if variables := r.FormValue("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &p.Variables); err != nil {
+					writeError(w, http.StatusBadRequest, "The variables are not valid JSON: "+err.Error())
+					return
+				}
+			}
+		}
+		if p.Query == "" {
+			writeError(w, http.StatusBadRequest, "A query is needed")
+			return
+		}
+		ctx := context.WithValue(r.Context(), requestKey, &request{lob: lob})
+		response := schema.Exec(ctx, p.Query, p.OperationName, p.Variables)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Printf("Error when writing a GraphQL response: %s", err)
+		}
+	}
+}
+
+//answers with the status and an error in the shape of GraphQL errors
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]map[string]string{"errors": {{"message": message}}})
+}
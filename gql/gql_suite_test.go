@@ -0,0 +1,284 @@
+package gql_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dacero/labyrinth-of-babel/gql"
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+)
+
+func TestGql(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Gql Suite")
+}
+
+var _ = Describe("GraphQL", func() {
+	var (
+		lob *countingRepository
+		rr  *httptest.ResponseRecorder
+	)
+	post := func(query string, variables map[string]interface{}) {
+		body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		Expect(err).To(BeNil())
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+		rr = httptest.NewRecorder()
+		gql.Handler(lob)(rr, req)
+	}
+	decode := func(data interface{}) {
+		var response struct {
+			Data   json.RawMessage
+			Errors []struct{ Message string }
+		}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.Errors).To(BeEmpty())
+		Expect(json.Unmarshal(response.Data, data)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		//a star of three cells around the center, each linked to two cells of its own
+		lob = newCountingRepository()
+		lob.add(models.Cell{Id: "center", Title: "Center", Room: "Garden"}, "Borges")
+		for _, arm := range []string{"a", "b", "c"} {
+			lob.add(models.Cell{Id: arm, Title: "Arm " + arm, Room: "Garden"}, "Ficciones")
+			lob.link("center", arm)
+			for _, leaf := range []string{"1", "2"} {
+				lob.add(models.Cell{Id: arm + leaf, Title: "Leaf " + arm + leaf, Room: "Library"}, "El Aleph")
+				lob.link(arm, arm+leaf)
+			}
+		}
+	})
+
+	It("should walk the links of the links of a cell with a query per level", func() {
+		post(`query($id: ID!) { cell(id: $id) { title links { title links { id sources { name } } } } }`,
+			map[string]interface{}{"id": "center"})
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		var data struct {
+			Cell struct {
+				Title string
+				Links []struct {
+					Title string
+					Links []struct {
+						Id      string
+						Sources []struct{ Name string }
+					}
+				}
+			}
+		}
+		decode(&data)
+		Expect(data.Cell.Title).To(Equal("Center"))
+		Expect(len(data.Cell.Links)).To(Equal(3))
+		leaves := 0
+		for _, arm := range data.Cell.Links {
+			for _, linked := range arm.Links {
+				if linked.Id != "center" {
+					leaves++
+					Expect(linked.Sources[0].Name).To(Equal("El Aleph"))
+				}
+			}
+		}
+		Expect(leaves).To(Equal(6))
+		Expect(lob.calls).To(Equal(map[string]int{"GetCell": 1, "GetLinksOfCells": 2, "GetSourcesOfCells": 1}))
+	})
+
+	It("should reach the rooms and the cells of sources", func() {
+		post(`{ cell(id: "a") { room { name cellCount } sources { name cells { title } } } }`, nil)
+		var data struct {
+			Cell struct {
+				Room struct {
+					Name      string
+					CellCount int
+				}
+				Sources []struct {
+					Name  string
+					Cells []struct{ Title string }
+				}
+			}
+		}
+		decode(&data)
+		Expect(data.Cell.Room.Name).To(Equal("Garden"))
+		Expect(data.Cell.Room.CellCount).To(Equal(4))
+		Expect(data.Cell.Sources[0].Name).To(Equal("Ficciones"))
+		Expect(len(data.Cell.Sources[0].Cells)).To(Equal(3))
+	})
+
+	It("should list the cells of every room with a query per level", func() {
+		post(`{ rooms { name cells { title room { name cells { id } } } } }`, nil)
+		var data struct {
+			Rooms []struct {
+				Name  string
+				Cells []struct {
+					Title string
+					Room  struct {
+						Name  string
+						Cells []struct{ Id string }
+					}
+				}
+			}
+		}
+		decode(&data)
+		Expect(data.Rooms).To(HaveLen(2))
+		Expect(data.Rooms[0].Name).To(Equal("Garden"))
+		Expect(data.Rooms[0].Cells).To(HaveLen(4))
+		Expect(data.Rooms[1].Cells).To(HaveLen(6))
+		Expect(data.Rooms[1].Cells[0].Room.Cells).To(HaveLen(6))
+		Expect(lob.calls).To(Equal(map[string]int{"ListRooms": 2, "ListCellsInRooms": 2}))
+	})
+
+	It("should answer null for cells that do not exist", func() {
+		post(`{ cell(id: "nowhere") { title } }`, nil)
+		Expect(rr.Body.String()).To(ContainSubstring(`"cell":null`))
+	})
+
+	It("should answer queries sent in the URL", func() {
+		req := httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{ rooms { name } }`), nil)
+		rr = httptest.NewRecorder()
+		gql.Handler(lob)(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Body.String()).To(ContainSubstring(`"name":"Library"`))
+	})
+
+	It("should refuse requests without a query", func() {
+		post("", nil)
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should refuse queries nested too deeply", func() {
+		post(`{ cell(id: "a") { links { links { links { links { id } } } } } }`, nil)
+		Expect(rr.Body.String()).To(ContainSubstring("exceeds max depth 5"))
+	})
+
+	It("should fail queries resolving too many cells", func() {
+		//forty cells linked to the center and to each other
+		for i := 0; i < 40; i++ {
+			id := fmt.Sprintf("n%d", i)
+			lob.add(models.Cell{Id: id, Title: "Node " + id, Room: "Garden"}, "Borges")
+			lob.link("center", id)
+			for j := 0; j < i; j++ {
+				lob.link(id, fmt.Sprintf("n%d", j))
+			}
+		}
+		post(`{ cell(id: "center") { links { links { id } } } }`, nil)
+		Expect(rr.Body.String()).To(ContainSubstring("more than 1000 cells and sources"))
+		post(`{ cell(id: "center") { links { id } } }`, nil)
+		var data struct {
+			Cell struct{ Links []struct{ Id string } }
+		}
+		decode(&data)
+		Expect(len(data.Cell.Links)).To(Equal(43))
+	})
+})
+
+//a labyrinth kept in memory that counts the queries made to it
+type countingRepository struct {
+	repository.LobRepository
+	mutex   sync.Mutex
+	calls   map[string]int
+	cells   map[string]models.Cell
+	order   []string
+	links   map[string][]string
+	sources map[string][]models.Source
+}
+
+func newCountingRepository() *countingRepository {
+	return &countingRepository{calls: make(map[string]int),
+		cells:   make(map[string]models.Cell),
+		links:   make(map[string][]string),
+		sources: make(map[string][]models.Source)}
+}
+
+func (m *countingRepository) add(cell models.Cell, source string) {
+	m.cells[cell.Id] = cell
+	m.order = append(m.order, cell.Id)
+	m.sources[cell.Id] = []models.Source{{Source: source}}
+}
+
+func (m *countingRepository) link(idA string, idB string) {
+	m.links[idA] = append(m.links[idA], idB)
+	m.links[idB] = append(m.links[idB], idA)
+}
+
+func (m *countingRepository) count(method string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.calls[method]++
+}
+
+func (m *countingRepository) GetCell(id string) (models.Cell, error) {
+	m.count("GetCell")
+	cell, ok := m.cells[id]
+	if !ok {
+		return cell, sql.ErrNoRows
+	}
+	return cell, nil
+}
+
+func (m *countingRepository) GetLinksOfCells(ids []string) (map[string][]models.Cell, error) {
+	m.count("GetLinksOfCells")
+	links := make(map[string][]models.Cell)
+	for _, id := range ids {
+		for _, other := range m.links[id] {
+			links[id] = append(links[id], m.cells[other])
+		}
+	}
+	return links, nil
+}
+
+func (m *countingRepository) GetSourcesOfCells(ids []string) (map[string][]models.Source, error) {
+	m.count("GetSourcesOfCells")
+	sources := make(map[string][]models.Source)
+	for _, id := range ids {
+		sources[id] = m.sources[id]
+	}
+	return sources, nil
+}
+
+func (m *countingRepository) ListCellsWithSources(names []string) (map[string][]models.Cell, error) {
+	m.count("ListCellsWithSources")
+	cells := make(map[string][]models.Cell)
+	for _, name := range names {
+		for _, id := range m.order {
+			if m.sources[id][0].Source == name {
+				cells[name] = append(cells[name], m.cells[id])
+			}
+		}
+	}
+	return cells, nil
+}
+
+func (m *countingRepository) ListRooms() ([]models.CollectionOfCells, error) {
+	m.count("ListRooms")
+	counts := make(map[string]int)
+	var rooms []models.CollectionOfCells
+	for _, id := range m.order {
+		counts[m.cells[id].Room]++
+	}
+	for _, name := range []string{"Garden", "Library"} {
+		rooms = append(rooms, models.CollectionOfCells{Name: name, CellCount: counts[name]})
+	}
+	return rooms, nil
+}
+
+func (m *countingRepository) ListCellsInRooms(rooms []string) (map[string][]models.Cell, error) {
+	m.count("ListCellsInRooms")
+	cells := make(map[string][]models.Cell)
+	for _, room := range rooms {
+		for _, id := range m.order {
+			if m.cells[id].Room == room {
+				cells[room] = append(cells[room], m.cells[id])
+			}
+		}
+	}
+	return cells, nil
+}
@@ -0,0 +1,403 @@
+package gql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//what the resolvers of a query share
+type request struct {
+	lob       repository.LobRepository
+	roomsOnce sync.Once
+	rooms     map[string]models.CollectionOfCells
+	roomsErr  error
+
+	nodesMutex sync.Mutex
+	nodes      int
+}
+
+var errTooManyNodes = fmt.Errorf("The query resolves more than %d cells and sources", maxNodes)
+
+//counts cells and sources as they are resolved, failing once there are too many
+func (req *request) resolving(count int) error {
+	req.nodesMutex.Lock()
+	defer req.nodesMutex.Unlock()
+	req.nodes += count
+	if req.nodes > maxNodes {
+		return errTooManyNodes
+	}
+	return nil
+}
+
+func requestOf(ctx context.Context) *request {
+	return ctx.Value(requestKey).(*request)
+}
+
+//the rooms of the labyrinth by name, read once per query
+func (req *request) room(name string) (models.CollectionOfCells, error) {
+	req.roomsOnce.Do(func() {
+		var rooms []models.CollectionOfCells
+		rooms, req.roomsErr = req.lob.ListRooms()
+		req.rooms = make(map[string]models.CollectionOfCells, len(rooms))
+		for _, room := range rooms {
+			req.rooms[room.Name] = room
+		}
+	})
+	if room, ok := req.rooms[name]; ok {
+		return room, req.roomsErr
+	}
+	return models.CollectionOfCells{Name: name}, req.roomsErr
+}
+
+//the cells of a list, whose links, sources, tags and the cells of their
+//rooms are loaded for all of them the first time they are asked for one of
+//them; a query walking the links of the links of a cell makes a query per
+//level, not one per cell
+type batch struct {
+	req   *request
+	ids   []string
+	rooms []string
+
+	linksOnce sync.Once
+	links     map[string][]models.Cell
+	linked    *batch
+	linksErr  error
+
+	sourcesOnce sync.Once
+	sources     map[string][]models.Source
+	sourced     *sourceBatch
+	sourcesErr  error
+
+	tagsOnce sync.Once
+	tags     map[string][]models.Tag
+	tagsErr  error
+
+	roomsOnce sync.Once
+	roomed    *roomBatch
+}
+
+func newBatch(req *request, cells []models.Cell) *batch {
+	b := &batch{req: req}
+	seen := make(map[string]bool)
+	for _, cell := range cells {
+		if !seen[cell.Id] {
+			seen[cell.Id] = true
+			b.ids = append(b.ids, cell.Id)
+		}
+		if !seen["room "+cell.Room] {
+			seen["room "+cell.Room] = true
+			b.rooms = append(b.rooms, cell.Room)
+		}
+	}
+	return b
+}
+
+//returns the resolvers of the cells, loaded together
+func (b *batch) resolve(cells []models.Cell) ([]*cellResolver, error) {
+	if err := b.req.resolving(len(cells)); err != nil {
+		return nil, err
+	}
+	resolvers := []*cellResolver{}
+	for _, cell := range cells {
+		resolvers = append(resolvers, &cellResolver{cell: cell, batch: b})
+	}
+	return resolvers, nil
+}
+
+func resolveCells(req *request, cells []models.Cell) ([]*cellResolver, error) {
+	return newBatch(req, cells).resolve(cells)
+}
+
+func (b *batch) linksOf(id string) ([]*cellResolver, error) {
+	b.linksOnce.Do(func() {
+		b.links, b.linksErr = b.req.lob.GetLinksOfCells(b.ids)
+		var all []models.Cell
+		for _, id := range b.ids {
+			all = append(all, b.links[id]...)
+		}
+		b.linked = newBatch(b.req, all)
+	})
+	if b.linksErr != nil {
+		return nil, b.linksErr
+	}
+	return b.linked.resolve(b.links[id])
+}
+
+func (b *batch) sourcesOf(id string) ([]*sourceResolver, error) {
+	b.sourcesOnce.Do(func() {
+		b.sources, b.sourcesErr = b.req.lob.GetSourcesOfCells(b.ids)
+		var all []models.Source
+		for _, id := range b.ids {
+			all = append(all, b.sources[id]...)
+		}
+		b.sourced = newSourceBatch(b.req, all)
+	})
+	if b.sourcesErr != nil {
+		return nil, b.sourcesErr
+	}
+	return b.sourced.resolve(b.sources[id])
+}
+
+func (b *batch) tagsOf(id string) ([]string, error) {
+	b.tagsOnce.Do(func() {
+		b.tags, b.tagsErr = b.req.lob.GetTagsOfCells(b.ids)
+	})
+	tags := []string{}
+	for _, tag := range b.tags[id] {
+		tags = append(tags, tag.Tag)
+	}
+	return tags, b.tagsErr
+}
+
+func (b *batch) roomOf(name string) (*roomResolver, error) {
+	b.roomsOnce.Do(func() {
+		b.roomed = newRoomBatch(b.req, b.rooms)
+	})
+	room, err := b.req.room(name)
+	return &roomResolver{room: room, batch: b.roomed}, err
+}
+
+//the rooms of a list, whose cells are loaded for all of them at once
+type roomBatch struct {
+	req   *request
+	names []string
+
+	cellsOnce sync.Once
+	cells     map[string][]models.Cell
+	cellBatch *batch
+	cellsErr  error
+}
+
+func newRoomBatch(req *request, names []string) *roomBatch {
+	return &roomBatch{req: req, names: names}
+}
+
+func (b *roomBatch) cellsOf(name string) ([]*cellResolver, error) {
+	b.cellsOnce.Do(func() {
+		b.cells, b.cellsErr = b.req.lob.ListCellsInRooms(b.names)
+		var all []models.Cell
+		for _, name := range b.names {
+			all = append(all, b.cells[name]...)
+		}
+		b.cellBatch = newBatch(b.req, all)
+	})
+	if b.cellsErr != nil {
+		return nil, b.cellsErr
+	}
+	return b.cellBatch.resolve(b.cells[name])
+}
+
+//the sources of a list, whose cells are loaded for all of them at once
+type sourceBatch struct {
+	req   *request
+	names []string
+
+	cellsOnce sync.Once
+	cells     map[string][]models.Cell
+	cellBatch *batch
+	cellsErr  error
+}
+
+func newSourceBatch(req *request, sources []models.Source) *sourceBatch {
+	b := &sourceBatch{req: req}
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		if !seen[source.Source] {
+			seen[source.Source] = true
+			b.names = append(b.names, source.Source)
+		}
+	}
+	return b
+}
+
+//returns the resolvers of the sources, whose cells are loaded together
+func (b *sourceBatch) resolve(sources []models.Source) ([]*sourceResolver, error) {
+	if err := b.req.resolving(len(sources)); err != nil {
+		return nil, err
+	}
+	resolvers := []*sourceResolver{}
+	for _, source := range sources {
+		resolvers = append(resolvers, &sourceResolver{name: source.Source, batch: b})
+	}
+	return resolvers, nil
+}
+
+func (b *sourceBatch) cellsOf(name string) ([]*cellResolver, error) {
+	b.cellsOnce.Do(func() {
+		b.cells, b.cellsErr = b.req.lob.ListCellsWithSources(b.names)
+		var all []models.Cell
+		for _, name := range b.names {
+			all = append(all, b.cells[name]...)
+		}
+		b.cellBatch = newBatch(b.req, all)
+	})
+	if b.cellsErr != nil {
+		return nil, b.cellsErr
+	}
+	return b.cellBatch.resolve(b.cells[name])
+}
+
+type query struct{}
+
+//a cell that does not exist, or is private, is null
+func (q *query) Cell(ctx context.Context, args struct{ Id graphql.ID }) (*cellResolver, error) {
+	req := requestOf(ctx)
+	return oneCell(req, func() (models.Cell, error) { return req.lob.GetCell(string(args.Id)) })
+}
+
+func (q *query) CellByTitle(ctx context.Context, args struct{ Title string }) (*cellResolver, error) {
+	req := requestOf(ctx)
+	return oneCell(req, func() (models.Cell, error) { return req.lob.GetCellByTitle(args.Title) })
+}
+
+func oneCell(req *request, get func() (models.Cell, error)) (*cellResolver, error) {
+	cell, err := get()
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	resolvers, err := resolveCells(req, []models.Cell{cell})
+	if err != nil {
+		return nil, err
+	}
+	return resolvers[0], nil
+}
+
+func (q *query) Search(ctx context.Context, args struct {
+	Text string
+	Tags *[]string
+}) ([]*cellResolver, error) {
+	var tags []string
+	if args.Tags != nil {
+		tags = *args.Tags
+	}
+	req := requestOf(ctx)
+	return resolveCells(req, req.lob.SearchCellsWithTags(args.Text, tags))
+}
+
+func (q *query) Rooms(ctx context.Context) ([]*roomResolver, error) {
+	req := requestOf(ctx)
+	rooms, err := req.lob.ListRooms()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, room := range rooms {
+		names = append(names, room.Name)
+	}
+	b := newRoomBatch(req, names)
+	resolvers := []*roomResolver{}
+	for _, room := range rooms {
+		resolvers = append(resolvers, &roomResolver{room: room, batch: b})
+	}
+	return resolvers, nil
+}
+
+//a room without cells, or private, is null
+func (q *query) Room(ctx context.Context, args struct{ Name string }) (*roomResolver, error) {
+	req := requestOf(ctx)
+	room, err := req.room(args.Name)
+	if err != nil || room.CellCount == 0 {
+		return nil, err
+	}
+	return &roomResolver{room: room, batch: newRoomBatch(req, []string{room.Name})}, nil
+}
+
+func (q *query) Sources(ctx context.Context, args struct{ Search *string }) ([]*sourceResolver, error) {
+	term := ""
+	if args.Search != nil {
+		term = *args.Search
+	}
+	req := requestOf(ctx)
+	sources := req.lob.SearchSources(term)
+	return newSourceBatch(req, sources).resolve(sources)
+}
+
+type cellResolver struct {
+	cell  models.Cell
+	batch *batch
+}
+
+func (c *cellResolver) Id() graphql.ID {
+	return graphql.ID(c.cell.Id)
+}
+
+func (c *cellResolver) Title() string {
+	return c.cell.Title
+}
+
+func (c *cellResolver) Body() string {
+	return c.cell.Body
+}
+
+func (c *cellResolver) Private() bool {
+	return c.cell.Private
+}
+
+func (c *cellResolver) Created() string {
+	return c.cell.Create_time.Format(time.RFC3339)
+}
+
+func (c *cellResolver) Updated() string {
+	return c.cell.Update_time.Format(time.RFC3339)
+}
+
+func (c *cellResolver) Room() (*roomResolver, error) {
+	return c.batch.roomOf(c.cell.Room)
+}
+
+func (c *cellResolver) Links() ([]*cellResolver, error) {
+	return c.batch.linksOf(c.cell.Id)
+}
+
+func (c *cellResolver) Sources() ([]*sourceResolver, error) {
+	return c.batch.sourcesOf(c.cell.Id)
+}
+
+func (c *cellResolver) Tags() ([]string, error) {
+	return c.batch.tagsOf(c.cell.Id)
+}
+
+type roomResolver struct {
+	room  models.CollectionOfCells
+	batch *roomBatch
+}
+
+func (r *roomResolver) Name() string {
+	return r.room.Name
+}
+
+func (r *roomResolver) Private() bool {
+	return r.room.Private
+}
+
+func (r *roomResolver) CellCount() int32 {
+	return int32(r.room.CellCount)
+}
+
+func (r *roomResolver) Cells() ([]*cellResolver, error) {
+	return r.batch.cellsOf(r.room.Name)
+}
+
+type sourceResolver struct {
+	name  string
+	batch *sourceBatch
+}
+
+func (s *sourceResolver) Name() string {
+	return s.name
+}
+
+func (s *sourceResolver) Cells() ([]*cellResolver, error) {
+	return s.batch.cellsOf(s.name)
+}
@@ -0,0 +1,196 @@
+package graph
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+)
+
+const (
+	damping    = 0.85
+	iterations = 100
+)
+
+//the degree and centrality of a cell
+type CellScore struct {
+	Cell       models.Cell
+	Degree     int
+	Centrality float64
+}
+
+//the shape of the labyrinth at a given time
+type Analysis struct {
+	Graph *Graph
+	//every cell, the most central first
+	Cells []CellScore
+	//groups of connected cells, biggest first
+	Components [][]models.Cell
+	//every component but the biggest one
+	Islands  [][]models.Cell
+	Bridges  []RoomBridge
+	Computed time.Time
+}
+
+//returns the n most central cells
+func (a Analysis) Top(n int) []CellScore {
+	if n > len(a.Cells) {
+		n = len(a.Cells)
+	}
+	return a.Cells[:n]
+}
+
+//computes degree, components, bridges and centrality of the graph
+func Analyze(g *Graph) Analysis {
+	analysis := Analysis{Graph: g, Bridges: g.RoomBridges(), Computed: time.Now()}
+
+	rank := g.PageRank(damping, iterations)
+	for _, cell := range g.Cells() {
+		analysis.Cells = append(analysis.Cells, CellScore{Cell: cell, Degree: g.Degree(cell.Id), Centrality: rank[cell.Id]})
+	}
+	sort.SliceStable(analysis.Cells, func(i, j int) bool {
+		return analysis.Cells[i].Centrality > analysis.Cells[j].Centrality
+	})
+
+	for _, ids := range g.Components() {
+		component := make([]models.Cell, 0, len(ids))
+		for _, id := range ids {
+			cell, _ := g.Cell(id)
+			component = append(component, cell)
+		}
+		analysis.Components = append(analysis.Components, component)
+	}
+	if len(analysis.Components) > 1 {
+		analysis.Islands = analysis.Components[1:]
+	}
+	return analysis
+}
+
+//what the analyzer needs from the repository
+type Loader interface {
+	ListCells() ([]models.Cell, error)
+	ListLinks() ([]models.Link, error)
+}
+
+//keeps the analysis of the labyrinth until it changes
+type Analyzer struct {
+	loader   Loader
+	mutex    sync.Mutex
+	analysis *Analysis
+}
+
+func NewAnalyzer(loader Loader) *Analyzer {
+	return &Analyzer{loader: loader}
+}
+
+//returns the cached analysis, computing it if the labyrinth changed since the last time
+func (a *Analyzer) Analysis() (Analysis, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.analysis != nil {
+		return *a.analysis, nil
+	}
+	cells, err := a.loader.ListCells()
+	if err != nil {
+		return Analysis{}, err
+	}
+	links, err := a.loader.ListLinks()
+	if err != nil {
+		return Analysis{}, err
+	}
+	analysis := Analyze(New(cells, links))
+	a.analysis = &analysis
+	return analysis, nil
+}
+
+//discards the cached analysis
+func (a *Analyzer) Invalidate() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.analysis = nil
+}
+
+//anything computed from the cells and links that must be discarded when they change
+type Cache interface {
+	Invalidate()
+}
+
+//Watch returns a repository that invalidates the caches whenever cells, their
+//sources or links change
+func Watch(lob repository.LobRepository, caches ...Cache) repository.LobRepository {
+	return &watchedRepository{LobRepository: lob, caches: caches}
+}
+
+type watchedRepository struct {
+	repository.LobRepository
+	caches []Cache
+}
+
+func (w *watchedRepository) invalidate(err error) {
+	if err != nil {
+		return
+	}
+	for _, cache := range w.caches {
+		cache.Invalidate()
+	}
+}
+
+func (w *watchedRepository) UpdateCell(cell models.Cell) (int64, error) {
+	updated, err := w.LobRepository.UpdateCell(cell)
+	w.invalidate(err)
+	return updated, err
+}
+
+func (w *watchedRepository) NewCell(cell models.Cell) (string, error) {
+	id, err := w.LobRepository.NewCell(cell)
+	w.invalidate(err)
+	return id, err
+}
+
+func (w *watchedRepository) DeleteCell(id string) error {
+	err := w.LobRepository.DeleteCell(id)
+	w.invalidate(err)
+	return err
+}
+
+func (w *watchedRepository) LinkCells(idA string, idB string) error {
+	err := w.LobRepository.LinkCells(idA, idB)
+	w.invalidate(err)
+	return err
+}
+
+func (w *watchedRepository) UnlinkCells(idA string, idB string) error {
+	err := w.LobRepository.UnlinkCells(idA, idB)
+	w.invalidate(err)
+	return err
+}
+
+func (w *watchedRepository) AddSourceToCell(cellId string, source models.Source) (models.Cell, error) {
+	cell, err := w.LobRepository.AddSourceToCell(cellId, source)
+	w.invalidate(err)
+	return cell, err
+}
+
+func (w *watchedRepository) RemoveSourceFromCell(cellId string, source models.Source) (models.Cell, error) {
+	cell, err := w.LobRepository.RemoveSourceFromCell(cellId, source)
+	w.invalidate(err)
+	return cell, err
+}
+
+func (w *watchedRepository) SetRoomPrivate(room string, private bool) error {
+	err := w.LobRepository.SetRoomPrivate(room, private)
+	w.invalidate(err)
+	return err
+}
+
+//the public view is watched as well, it changes with the labyrinth
+func (w *watchedRepository) Public() repository.LobRepository {
+	return &watchedRepository{LobRepository: w.LobRepository.Public(), caches: w.caches}
+}
+
+//so are the changes made by a user
+func (w *watchedRepository) WithActor(user models.User) repository.LobRepository {
+	return &watchedRepository{LobRepository: w.LobRepository.WithActor(user), caches: w.caches}
+}
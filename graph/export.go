@@ -0,0 +1,189 @@
+package graph
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+)
+
+//a serialisation of the graph that other tools understand
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	write       func(w io.Writer, g *Graph) error
+}
+
+var Formats = map[string]Format{
+	"dot":     Format{Name: "dot", Extension: "dot", ContentType: "text/vnd.graphviz", write: WriteDOT},
+	"graphml": Format{Name: "graphml", Extension: "graphml", ContentType: "application/graphml+xml", write: WriteGraphML},
+	"gexf":    Format{Name: "gexf", Extension: "gexf", ContentType: "application/gexf+xml", write: WriteGEXF},
+}
+
+func (f Format) Write(w io.Writer, g *Graph) error {
+	return f.write(w, g)
+}
+
+func joinSources(sources []models.Source) string {
+	names := make([]string, 0, len(sources))
+	for _, source := range sources {
+		names = append(names, source.String())
+	}
+	return strings.Join(names, "; ")
+}
+
+//writes the graph in Graphviz DOT format
+func WriteDOT(w io.Writer, g *Graph) error {
+	quote := func(s string) string {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		s = strings.ReplaceAll(s, "\n", `\n`)
+		return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+	}
+	if _, err := fmt.Fprintln(w, "graph labyrinth {"); err != nil {
+		return err
+	}
+	for _, cell := range g.Cells() {
+		_, err := fmt.Fprintf(w, "\t%s [label=%s, room=%s, sources=%s];\n",
+			quote(cell.Id), quote(cell.Summary()), quote(cell.Room), quote(joinSources(cell.Sources)))
+		if err != nil {
+			return err
+		}
+	}
+	for _, link := range g.Links() {
+		if _, err := fmt.Fprintf(w, "\t%s -- %s;\n", quote(link.CellA), quote(link.CellB)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+type graphMLKey struct {
+	Id   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	Id   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Id     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		Id          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+//writes the graph in GraphML format
+func WriteGraphML(w io.Writer, g *Graph) error {
+	doc := graphML{Keys: []graphMLKey{
+		graphMLKey{Id: "title", For: "node", Name: "title", Type: "string"},
+		graphMLKey{Id: "room", For: "node", Name: "room", Type: "string"},
+		graphMLKey{Id: "sources", For: "node", Name: "sources", Type: "string"},
+	}}
+	doc.Graph.Id = "labyrinth"
+	doc.Graph.EdgeDefault = "undirected"
+	for _, cell := range g.Cells() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{Id: cell.Id, Data: []graphMLData{
+			graphMLData{Key: "title", Value: cell.Summary()},
+			graphMLData{Key: "room", Value: cell.Room},
+			graphMLData{Key: "sources", Value: joinSources(cell.Sources)},
+		}})
+	}
+	for i, link := range g.Links() {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Id: fmt.Sprintf("e%d", i), Source: link.CellA, Target: link.CellB})
+	}
+	return writeXML(w, doc)
+}
+
+type gexfAttribute struct {
+	Id    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfNode struct {
+	Id        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	Id     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type gexf struct {
+	XMLName xml.Name `xml:"http://www.gexf.net/1.2draft gexf"`
+	Version string   `xml:"version,attr"`
+	Graph   struct {
+		DefaultEdgeType string `xml:"defaultedgetype,attr"`
+		Attributes      struct {
+			Class      string          `xml:"class,attr"`
+			Attributes []gexfAttribute `xml:"attribute"`
+		} `xml:"attributes"`
+		Nodes []gexfNode `xml:"nodes>node"`
+		Edges []gexfEdge `xml:"edges>edge"`
+	} `xml:"graph"`
+}
+
+//writes the graph in GEXF format, as used by Gephi
+func WriteGEXF(w io.Writer, g *Graph) error {
+	doc := gexf{Version: "1.2"}
+	doc.Graph.DefaultEdgeType = "undirected"
+	doc.Graph.Attributes.Class = "node"
+	doc.Graph.Attributes.Attributes = []gexfAttribute{
+		gexfAttribute{Id: "room", Title: "room", Type: "string"},
+		gexfAttribute{Id: "sources", Title: "sources", Type: "string"},
+	}
+	for _, cell := range g.Cells() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{Id: cell.Id, Label: cell.Summary(), AttValues: []gexfAttValue{
+			gexfAttValue{For: "room", Value: cell.Room},
+			gexfAttValue{For: "sources", Value: joinSources(cell.Sources)},
+		}})
+	}
+	for i, link := range g.Links() {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{Id: fmt.Sprintf("e%d", i), Source: link.CellA, Target: link.CellB})
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
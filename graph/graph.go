@@ -0,0 +1,217 @@
+package graph
+
+import (
+	"math"
+	"sort"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+)
+
+//an undirected graph of the cells of the labyrinth and the links among them
+type Graph struct {
+	ids       []string
+	cells     map[string]models.Cell
+	adjacency map[string][]string
+	links     []models.Link
+}
+
+//number of links between cells of two different rooms
+type RoomBridge struct {
+	RoomA string
+	RoomB string
+	Links int
+}
+
+//builds the graph, links pointing to cells not in cells are ignored
+func New(cells []models.Cell, links []models.Link) *Graph {
+	g := &Graph{
+		cells:     make(map[string]models.Cell, len(cells)),
+		adjacency: make(map[string][]string, len(cells)),
+	}
+	for _, cell := range cells {
+		if _, exists := g.cells[cell.Id]; exists {
+			continue
+		}
+		g.ids = append(g.ids, cell.Id)
+		g.cells[cell.Id] = cell
+	}
+	seen := make(map[models.Link]bool, len(links))
+	for _, link := range links {
+		_, okA := g.cells[link.CellA]
+		_, okB := g.cells[link.CellB]
+		reversed := models.Link{CellA: link.CellB, CellB: link.CellA}
+		if !okA || !okB || link.CellA == link.CellB || seen[link] || seen[reversed] {
+			continue
+		}
+		seen[link] = true
+		g.links = append(g.links, link)
+		g.adjacency[link.CellA] = append(g.adjacency[link.CellA], link.CellB)
+		g.adjacency[link.CellB] = append(g.adjacency[link.CellB], link.CellA)
+	}
+	return g
+}
+
+//returns the cells of the graph in the order they were added
+func (g *Graph) Cells() []models.Cell {
+	cells := make([]models.Cell, 0, len(g.ids))
+	for _, id := range g.ids {
+		cells = append(cells, g.cells[id])
+	}
+	return cells
+}
+
+func (g *Graph) Links() []models.Link {
+	return g.links
+}
+
+func (g *Graph) Cell(id string) (models.Cell, bool) {
+	cell, ok := g.cells[id]
+	return cell, ok
+}
+
+//returns the number of links of a cell
+func (g *Graph) Degree(id string) int {
+	return len(g.adjacency[id])
+}
+
+//returns the graph restricted to the cells for which keep returns true
+func (g *Graph) Filter(keep func(models.Cell) bool) *Graph {
+	var cells []models.Cell
+	for _, id := range g.ids {
+		if keep(g.cells[id]) {
+			cells = append(cells, g.cells[id])
+		}
+	}
+	return New(cells, g.links)
+}
+
+//returns the ids of the cells in each connected component, biggest first
+func (g *Graph) Components() [][]string {
+	var components [][]string
+	visited := make(map[string]bool, len(g.ids))
+	for _, id := range g.ids {
+		if visited[id] {
+			continue
+		}
+		component := []string{id}
+		visited[id] = true
+		for i := 0; i < len(component); i++ {
+			for _, next := range g.adjacency[component[i]] {
+				if !visited[next] {
+					visited[next] = true
+					component = append(component, next)
+				}
+			}
+		}
+		components = append(components, component)
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i]) > len(components[j])
+	})
+	return components
+}
+
+//returns how many links join each pair of rooms, most connected first
+func (g *Graph) RoomBridges() []RoomBridge {
+	counts := make(map[[2]string]int)
+	for _, link := range g.links {
+		roomA, roomB := g.cells[link.CellA].Room, g.cells[link.CellB].Room
+		if roomA == roomB {
+			continue
+		}
+		if roomB < roomA {
+			roomA, roomB = roomB, roomA
+		}
+		counts[[2]string{roomA, roomB}]++
+	}
+	bridges := make([]RoomBridge, 0, len(counts))
+	for rooms, count := range counts {
+		bridges = append(bridges, RoomBridge{RoomA: rooms[0], RoomB: rooms[1], Links: count})
+	}
+	sort.Slice(bridges, func(i, j int) bool {
+		if bridges[i].Links != bridges[j].Links {
+			return bridges[i].Links > bridges[j].Links
+		}
+		if bridges[i].RoomA != bridges[j].RoomA {
+			return bridges[i].RoomA < bridges[j].RoomA
+		}
+		return bridges[i].RoomB < bridges[j].RoomB
+	})
+	return bridges
+}
+
+//computes a PageRank score for every cell, links count in both directions
+//cells without links share their score with the whole labyrinth
+func (g *Graph) PageRank(damping float64, iterations int) map[string]float64 {
+	n := float64(len(g.ids))
+	rank := make(map[string]float64, len(g.ids))
+	if n == 0 {
+		return rank
+	}
+	for _, id := range g.ids {
+		rank[id] = 1 / n
+	}
+	for i := 0; i < iterations; i++ {
+		dangling := 0.0
+		for _, id := range g.ids {
+			if len(g.adjacency[id]) == 0 {
+				dangling += rank[id]
+			}
+		}
+		next := make(map[string]float64, len(g.ids))
+		delta := 0.0
+		for _, id := range g.ids {
+			score := (1-damping)/n + damping*dangling/n
+			for _, neighbour := range g.adjacency[id] {
+				score += damping * rank[neighbour] / float64(len(g.adjacency[neighbour]))
+			}
+			next[id] = score
+			delta += math.Abs(score - rank[id])
+		}
+		rank = next
+		if delta < 1e-9 {
+			break
+		}
+	}
+	return rank
+}
+
+//returns the graph restricted to the cells within depth links of a cell
+func (g *Graph) Neighbourhood(id string, depth int) *Graph {
+	distances := map[string]int{}
+	if _, ok := g.cells[id]; ok {
+		distances[id] = 0
+	}
+	frontier := []string{id}
+	for distance := 1; distance <= depth && len(frontier) > 0; distance++ {
+		var next []string
+		for _, current := range frontier {
+			for _, neighbour := range g.adjacency[current] {
+				if _, seen := distances[neighbour]; !seen {
+					distances[neighbour] = distance
+					next = append(next, neighbour)
+				}
+			}
+		}
+		frontier = next
+	}
+	return g.Filter(func(cell models.Cell) bool {
+		_, ok := distances[cell.Id]
+		return ok
+	})
+}
+
+//returns the part of the graph in a room and within depth links of a cell,
+//empty room or cell id mean no restriction
+func (g *Graph) Restrict(room string, cellId string, depth int) *Graph {
+	restricted := g
+	if cellId != "" {
+		restricted = restricted.Neighbourhood(cellId, depth)
+	}
+	if room != "" {
+		restricted = restricted.Filter(func(cell models.Cell) bool {
+			return cell.Room == room
+		})
+	}
+	return restricted
+}
@@ -0,0 +1,213 @@
+package graph_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dacero/labyrinth-of-babel/graph"
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+)
+
+func TestGraph(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Graph Suite")
+}
+
+var _ = Describe("Graph", func() {
+	var (
+		g     *graph.Graph
+		cells = []models.Cell{
+			models.Cell{Id: "hub", Room: "Borges"},
+			models.Cell{Id: "a", Room: "Borges"},
+			models.Cell{Id: "b", Room: "Borges"},
+			models.Cell{Id: "c", Room: "Ethics"},
+			models.Cell{Id: "island-a", Room: "Ethics"},
+			models.Cell{Id: "island-b", Room: "Ethics"},
+			models.Cell{Id: "alone", Room: "Ethics"},
+		}
+		links = []models.Link{
+			models.Link{CellA: "hub", CellB: "a"},
+			models.Link{CellA: "b", CellB: "hub"},
+			models.Link{CellA: "hub", CellB: "c"},
+			models.Link{CellA: "island-a", CellB: "island-b"},
+			//duplicated, reversed and dangling links are ignored
+			models.Link{CellA: "a", CellB: "hub"},
+			models.Link{CellA: "hub", CellB: "missing"},
+		}
+	)
+
+	BeforeEach(func() {
+		g = graph.New(cells, links)
+	})
+
+	Describe("Computing the degree of a cell", func() {
+		It("should count each link once", func() {
+			Expect(g.Degree("hub")).To(Equal(3))
+			Expect(g.Degree("a")).To(Equal(1))
+			Expect(g.Degree("alone")).To(Equal(0))
+		})
+	})
+
+	Describe("Finding connected components", func() {
+		It("should return them biggest first", func() {
+			components := g.Components()
+			Expect(len(components)).To(Equal(3))
+			Expect(components[0]).To(ConsistOf("hub", "a", "b", "c"))
+			Expect(components[1]).To(ConsistOf("island-a", "island-b"))
+			Expect(components[2]).To(ConsistOf("alone"))
+		})
+	})
+
+	Describe("Finding bridges between rooms", func() {
+		It("should count links across rooms only", func() {
+			Expect(g.RoomBridges()).To(Equal([]graph.RoomBridge{
+				graph.RoomBridge{RoomA: "Borges", RoomB: "Ethics", Links: 1}}))
+		})
+	})
+
+	Describe("Computing centrality", func() {
+		It("should rank the hub first and add up to one", func() {
+			rank := g.PageRank(0.85, 100)
+			total := 0.0
+			for id, score := range rank {
+				total += score
+				if id != "hub" {
+					Expect(score).To(BeNumerically("<", rank["hub"]))
+				}
+			}
+			Expect(total).To(BeNumerically("~", 1.0, 1e-6))
+		})
+	})
+
+	Describe("Analyzing the labyrinth", func() {
+		It("should list the islands apart from the main component", func() {
+			analysis := graph.Analyze(g)
+			Expect(analysis.Top(1)[0].Cell.Id).To(Equal("hub"))
+			Expect(len(analysis.Islands)).To(Equal(2))
+		})
+	})
+
+	Describe("Restricting the graph", func() {
+		It("should keep the neighbourhood of a cell", func() {
+			restricted := g.Restrict("", "a", 1)
+			Expect(len(restricted.Cells())).To(Equal(2))
+			Expect(restricted.Links()).To(Equal([]models.Link{models.Link{CellA: "hub", CellB: "a"}}))
+		})
+		It("should keep the cells of a room", func() {
+			restricted := g.Restrict("Borges", "", 0)
+			Expect(len(restricted.Cells())).To(Equal(3))
+			Expect(len(restricted.Links())).To(Equal(2))
+		})
+	})
+
+	Describe("Exporting the graph", func() {
+		var out bytes.Buffer
+		BeforeEach(func() {
+			out.Reset()
+		})
+		It("should write every node and edge in DOT", func() {
+			Expect(graph.WriteDOT(&out, g)).To(Succeed())
+			Expect(strings.Count(out.String(), "room=")).To(Equal(len(cells)))
+			Expect(strings.Count(out.String(), " -- ")).To(Equal(4))
+		})
+		It("should write valid GraphML", func() {
+			Expect(graph.WriteGraphML(&out, g)).To(Succeed())
+			var doc struct {
+				Nodes []struct {
+					Id string `xml:"id,attr"`
+				} `xml:"graph>node"`
+				Edges []struct {
+					Source string `xml:"source,attr"`
+				} `xml:"graph>edge"`
+			}
+			Expect(xml.Unmarshal(out.Bytes(), &doc)).To(Succeed())
+			Expect(len(doc.Nodes)).To(Equal(len(cells)))
+			Expect(len(doc.Edges)).To(Equal(4))
+		})
+		It("should write valid GEXF", func() {
+			Expect(graph.WriteGEXF(&out, g)).To(Succeed())
+			var doc struct {
+				Nodes []struct {
+					Id string `xml:"id,attr"`
+				} `xml:"graph>nodes>node"`
+				Edges []struct {
+					Source string `xml:"source,attr"`
+				} `xml:"graph>edges>edge"`
+			}
+			Expect(xml.Unmarshal(out.Bytes(), &doc)).To(Succeed())
+			Expect(len(doc.Nodes)).To(Equal(len(cells)))
+			Expect(len(doc.Edges)).To(Equal(4))
+		})
+	})
+
+	Describe("Caching the analysis", func() {
+		var (
+			loader   *countingLoader
+			analyzer *graph.Analyzer
+		)
+		BeforeEach(func() {
+			loader = &countingLoader{cells: cells, links: links}
+			analyzer = graph.NewAnalyzer(loader)
+		})
+		It("should load the labyrinth only once until invalidated", func() {
+			_, err := analyzer.Analysis()
+			Expect(err).To(BeNil())
+			_, err = analyzer.Analysis()
+			Expect(err).To(BeNil())
+			Expect(loader.loads).To(Equal(1))
+			analyzer.Invalidate()
+			_, err = analyzer.Analysis()
+			Expect(err).To(BeNil())
+			Expect(loader.loads).To(Equal(2))
+		})
+		It("should be invalidated when the sources of a cell change", func() {
+			lob := graph.Watch(&sourcesRepository{}, analyzer)
+			_, err := analyzer.Analysis()
+			Expect(err).To(BeNil())
+			_, err = lob.AddSourceToCell("1", models.Source{Source: "Ficciones"})
+			Expect(err).To(BeNil())
+			_, err = analyzer.Analysis()
+			Expect(err).To(BeNil())
+			Expect(loader.loads).To(Equal(2))
+			_, err = lob.RemoveSourceFromCell("1", models.Source{Source: "Ficciones"})
+			Expect(err).To(BeNil())
+			_, err = analyzer.Analysis()
+			Expect(err).To(BeNil())
+			Expect(loader.loads).To(Equal(3))
+		})
+	})
+})
+
+//a labyrinth whose sources can be changed, and nothing else
+type sourcesRepository struct {
+	repository.LobRepository
+}
+
+func (s *sourcesRepository) AddSourceToCell(cellId string, source models.Source) (models.Cell, error) {
+	return models.Cell{Id: cellId}, nil
+}
+
+func (s *sourcesRepository) RemoveSourceFromCell(cellId string, source models.Source) (models.Cell, error) {
+	return models.Cell{Id: cellId}, nil
+}
+
+type countingLoader struct {
+	cells []models.Cell
+	links []models.Link
+	loads int
+}
+
+func (l *countingLoader) ListCells() ([]models.Cell, error) {
+	l.loads++
+	return l.cells, nil
+}
+
+func (l *countingLoader) ListLinks() ([]models.Link, error) {
+	return l.links, nil
+}
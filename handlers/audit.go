@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+)
+
+//entries shown in the audit page, the export has all of them
+const auditPageSize = 200
+
+//reads the filters of the audit log from the query: user, cell, and the
+//days from and to, both included, as 2006-01-02
+func auditFilter(r *http.Request) (models.AuditFilter, error) {
+	filter := models.AuditFilter{Username: r.FormValue("user"), CellId: r.FormValue("cell")}
+	if from := r.FormValue("from"); from != "" {
+		day, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return filter, err
+		}
+		filter.From = day
+	}
+	if to := r.FormValue("to"); to != "" {
+		day, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return filter, err
+		}
+		filter.To = day.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+//AuditHandler shows the newest changes made to the labyrinth, and who made them
+func AuditHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		filter, err := auditFilter(r)
+		if err != nil {
+			http.Error(w, "Dates go as 2006-01-02", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = auditPageSize
+		entries, err := lob.ListAudit(filter)
+		if err != nil {
+			log.Printf("Error when listing the audit log: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		t, err := parseTemplate(r, "audit.gohtml")
+		if err != nil {
+			log.Printf("Error when parsing the audit template: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		type data struct {
+			Entries []models.AuditEntry
+			User    string
+			Cell    string
+			From    string
+			To      string
+		}
+		err = t.Execute(w, data{Entries: entries,
+			User: r.FormValue("user"),
+			Cell: r.FormValue("cell"),
+			From: r.FormValue("from"),
+			To:   r.FormValue("to")})
+		if err != nil {
+			log.Printf("Error when returning the audit log: %s", err)
+		}
+	})
+}
+
+//AuditExportHandler downloads the entries of the audit log passing the same
+//filters as the audit page, oldest first, one JSON object per line
+func AuditExportHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		filter, err := auditFilter(r)
+		if err != nil {
+			http.Error(w, "Dates go as 2006-01-02", http.StatusBadRequest)
+			return
+		}
+		entries, err := lob.ListAudit(filter)
+		if err != nil {
+			log.Printf("Error when exporting the audit log: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		type line struct {
+			Id       int64           `json:"id"`
+			Time     time.Time       `json:"time"`
+			UserId   string          `json:"user_id,omitempty"`
+			Username string          `json:"username,omitempty"`
+			Action   string          `json:"action"`
+			Target   string          `json:"target"`
+			Before   json.RawMessage `json:"before,omitempty"`
+			After    json.RawMessage `json:"after,omitempty"`
+		}
+		w.Header().Set("Content-Type", "application/jsonl")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		encoder := json.NewEncoder(w)
+		for i := len(entries) - 1; i >= 0; i-- {
+			entry := entries[i]
+			err = encoder.Encode(line{Id: entry.Id,
+				Time:     entry.Create_time,
+				UserId:   entry.UserId,
+				Username: entry.Username,
+				Action:   entry.Action,
+				Target:   entry.Target,
+				Before:   rawJSON(entry.Before),
+				After:    rawJSON(entry.After)})
+			if err != nil {
+				log.Printf("Error when exporting the audit log: %s", err)
+				return
+			}
+		}
+	})
+}
+
+//JSON kept as it is, nil when empty so it is left out
+func rawJSON(value string) json.RawMessage {
+	if value == "" {
+		return nil
+	}
+	return json.RawMessage(value)
+}
@@ -3,42 +3,78 @@ package handlers
 import (
 	"net/http"
 	"log"
-	"os"
-	"fmt"
+	"time"
 
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/ratelimit"
+	"github.com/dacero/labyrinth-of-babel/repository"
 	"github.com/gorilla/sessions"
 )
 
-func Authenticate(store *sessions.CookieStore) func(w http.ResponseWriter, r *http.Request) {
+//LoginHandler shows the login page and logs users in with their username and password
+func LoginHandler(lob repository.LobRepository, store *sessions.CookieStore) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		session, err := store.Get(r, "lob-session")
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		lob := repository.FromContext(r.Context(), lob)
+		type data struct {
+			Username string
+			Redirect string
+			Error    string
 		}
-		
-		secret := r.FormValue("secret")
-		if secret != os.Getenv("LABYRINTH_SECRET") {
-			session.Values["authenticated"] = false
-			err = session.Save(r, w)
+		page := data{Redirect: r.FormValue("redirect")}
+		if r.Method == http.MethodPost {
+			page.Username = r.PostFormValue("username")
+			user, err := lob.GetUserByUsername(page.Username)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				models.CheckMissingUserPassword(r.PostFormValue("password"))
+			} else if user.CheckPassword(r.PostFormValue("password")) {
+				session, err := store.Get(r, sessionName(r))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				startSession(session, user.Id, time.Now())
+				err = session.Save(r, w)
+				if err != nil {
+					log.Print("Internal Server Error when saving the session")
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				redirect(w, r, redirectTarget(r, "/cell/"+currentLabyrinth(r).Entry), http.StatusFound)
 				return
 			}
+			log.Printf("Failed login as %q from %s", page.Username, ratelimit.ByAddress(r))
+			page.Error = "Wrong username or password"
 			w.WriteHeader(http.StatusUnauthorized)
-			fmt.Fprint(w, "Unauthorized")
-			return
 		}
 		
-		session.Values["authenticated"] = true
-		log.Print("It worked!")
+		t, err := parseTemplate(r, "login.gohtml")
+		if err != nil {
+			log.Printf("Error when parsing the login page: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = t.Execute(w, page)
+		if err != nil {
+			log.Printf("Error when returning the login page: %s", err)
+		}
+	})
+}
+
+//LogoutHandler forgets the user of the session
+func LogoutHandler(store *sessions.CookieStore) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := store.Get(r, sessionName(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		endSession(session)
+		session.Options.MaxAge = -1
 		err = session.Save(r, w)
 		if err != nil {
-			log.Print("Internal Server Error when saving the session")
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		
-		http.Redirect(w, r, "/cell/entry", http.StatusFound)
+		redirect(w, r, "/", http.StatusFound)
 	})
 }
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+//name of the form field and header carrying the token
+const (
+	csrfField  = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+//csrfTokens gives every session a random token that forms must send back,
+//so other sites cannot post on behalf of its user
+func csrfTokens(l Labyrinth) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			//store == nil is for testing purposes
+			if l.Store == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			//a broken cookie gets a new session, and a new token
+			session, _ := l.Store.Get(r, sessionName(r))
+			token, _ := session.Values[csrfField].(string)
+			if token == "" {
+				secret := make([]byte, 32)
+				if _, err := rand.Read(secret); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				token = base64.RawURLEncoding.EncodeToString(secret)
+				session.Values[csrfField] = token
+				if err := session.Save(r, w); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfKey, token)))
+		})
+	}
+}
+
+//returns the token forms of the session must send
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfKey).(string)
+	return token
+}
+
+//checkCSRF refuses requests that change something without the token of
+//their session; requests with an API token carry no cookie to abuse
+func checkCSRF(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next(w, r)
+			return
+		}
+		if _, ok := bearerToken(r); ok || currentLabyrinth(r).Store == nil {
+			next(w, r)
+			return
+		}
+		sent := r.Header.Get(csrfHeader)
+		if sent == "" {
+			sent = r.PostFormValue(csrfField)
+		}
+		expected := csrfToken(r)
+		if expected == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(expected)) != 1 {
+			deny(w, r, http.StatusForbidden, "The form expired, go back, reload it and try again")
+			return
+		}
+		next(w, r)
+	}
+}
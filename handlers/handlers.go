@@ -1,23 +1,33 @@
 package handlers
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"text/template"
+	"net/url"
 	"encoding/json"
+	"strconv"
+	"strings"
 
+	"github.com/dacero/labyrinth-of-babel/api"
 	"github.com/dacero/labyrinth-of-babel/repository"
 	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/graph"
+	"github.com/dacero/labyrinth-of-babel/similarity"
+	"github.com/dacero/labyrinth-of-babel/vault"
 	"github.com/gorilla/mux"
-	"github.com/gorilla/sessions"
 )
 
 func ViewHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		cellId := mux.Vars(r)["id"]
 		cell, err := lob.GetCell(cellId)
+		if represent(w, r, err, func() interface{} { return api.CellOf(cell) }, func() string { return cellMarkdown(cell) }) {
+			return
+		}
 		if err != nil {
 			log.Printf("Error when returning card: %s", err)
 			notFound, err := ioutil.ReadFile("./templates/card_not_found.html")
@@ -27,11 +37,19 @@ func ViewHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *ht
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(w, string(notFound))
 		} else {
-			t, err := template.ParseFiles("./templates/card.gohtml")
+			mentions, err := lob.GetUnlinkedMentions(cellId)
+			if err != nil {
+				log.Printf("Error when looking for unlinked mentions: %s", err)
+			}
+			t, err := parseTemplate(r, "card.gohtml")
 			if err != nil {
 				log.Printf("Error when returning card: %s", err)
 			}
-			err = t.Execute(w, cell)
+			type data struct {
+				models.Cell
+				UnlinkedMentions []models.Cell
+			}
+			err = t.Execute(w, data{Cell: cell, UnlinkedMentions: mentions})
 			if err != nil {
 				log.Printf("Error when returning card: %s", err)
 			}
@@ -39,12 +57,9 @@ func ViewHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *ht
 	})
 }
 
-func EditHandler(lob repository.LobRepository, store *sessions.CookieStore) func(w http.ResponseWriter, r *http.Request) {
+func EditHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if auth, _ := checkAuthorization(w, r, store); !auth {
-			http.Redirect(w, r, "/page/auth.html", http.StatusFound)
-		}
-		
+		lob := repository.FromContext(r.Context(), lob)
 		cellId := mux.Vars(r)["id"]
 		cell, err := lob.GetCell(cellId)
 		if err != nil {
@@ -56,7 +71,7 @@ func EditHandler(lob repository.LobRepository, store *sessions.CookieStore) func
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(w, string(notFound))
 		} else {
-			t, err := template.ParseFiles("./templates/edit_card.gohtml")
+			t, err := parseTemplate(r, "edit_card.gohtml")
 			if err != nil {
 				log.Printf("Error when returning card: %s", err)
 			}
@@ -68,12 +83,9 @@ func EditHandler(lob repository.LobRepository, store *sessions.CookieStore) func
 	})
 }
 
-func SourcesHandler(lob repository.LobRepository, store *sessions.CookieStore) func(w http.ResponseWriter, r *http.Request) {
+func SourcesHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if auth, _ := checkAuthorization(w, r, store); !auth {
-			http.Redirect(w, r, "/page/auth.html", http.StatusFound)
-		}
-		
+		lob := repository.FromContext(r.Context(), lob)
 		cellId := mux.Vars(r)["id"]
 		cell, err := lob.GetCell(cellId)
 		if err != nil {
@@ -85,7 +97,7 @@ func SourcesHandler(lob repository.LobRepository, store *sessions.CookieStore) f
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(w, string(notFound))
 		} else {
-			t, err := template.ParseFiles("./templates/edit_sources.gohtml")
+			t, err := parseTemplate(r, "edit_sources.gohtml")
 			if err != nil {
 				log.Printf("Error when returning card: %s", err)
 			}
@@ -99,6 +111,7 @@ func SourcesHandler(lob repository.LobRepository, store *sessions.CookieStore) f
 
 func AddSourceHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		cellId := mux.Vars(r)["id"]
 		newSource := models.Source{Source: r.PostFormValue("source") }
 		_, err := lob.AddSourceToCell(cellId, newSource)
@@ -111,13 +124,14 @@ func AddSourceHandler(lob repository.LobRepository) func(w http.ResponseWriter,
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(w, string(notFound))
 		} else {
-			http.Redirect(w, r, "/cell/"+cellId+"/sources", http.StatusFound)
+			redirect(w, r, "/cell/"+cellId+"/sources", http.StatusFound)
 		}
 	})
 }
 
 func RemoveSourceHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		cellId := mux.Vars(r)["id"]
 		source := models.Source{Source: r.PostFormValue("source") }
 		_, err := lob.RemoveSourceFromCell(cellId, source)
@@ -130,17 +144,57 @@ func RemoveSourceHandler(lob repository.LobRepository) func(w http.ResponseWrite
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(w, string(notFound))
 		} else {
-			http.Redirect(w, r, "/cell/"+cellId+"/sources", http.StatusFound)
+			redirect(w, r, "/cell/"+cellId+"/sources", http.StatusFound)
+		}
+	})
+}
+
+//the number of similar cells suggested when editing links
+const linkSuggestions = 5
+
+func AddTagHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		cellId := mux.Vars(r)["id"]
+		newTag := models.Tag{Tag: r.PostFormValue("tag")}
+		_, err := lob.AddTagToCell(cellId, newTag)
+		if err != nil {
+			log.Printf("Error when adding tag: %s", err)
+			notFound, err := ioutil.ReadFile("./templates/card_not_found.html")
+			if err != nil {
+				log.Printf("Error when adding tag: %s", err)
+			}
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, string(notFound))
+		} else {
+			redirect(w, r, "/cell/"+cellId+"/edit", http.StatusFound)
 		}
 	})
 }
 
-func LinksHandler(lob repository.LobRepository, store *sessions.CookieStore) func(w http.ResponseWriter, r *http.Request) {
+func RemoveTagHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if auth, _ := checkAuthorization(w, r, store); !auth {
-			http.Redirect(w, r, "/page/auth.html", http.StatusFound)
+		lob := repository.FromContext(r.Context(), lob)
+		cellId := mux.Vars(r)["id"]
+		tag := models.Tag{Tag: r.PostFormValue("tag")}
+		_, err := lob.RemoveTagFromCell(cellId, tag)
+		if err != nil {
+			log.Printf("Error when removing tag: %s", err)
+			notFound, err := ioutil.ReadFile("./templates/card_not_found.html")
+			if err != nil {
+				log.Printf("Error when removing tag: %s", err)
+			}
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, string(notFound))
+		} else {
+			redirect(w, r, "/cell/"+cellId+"/edit", http.StatusFound)
 		}
-		
+	})
+}
+
+func LinksHandler(lob repository.LobRepository, suggester *similarity.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		cellId := mux.Vars(r)["id"]
 		cell, err := lob.GetCell(cellId)
 		if err != nil {
@@ -152,11 +206,19 @@ func LinksHandler(lob repository.LobRepository, store *sessions.CookieStore) fun
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(w, string(notFound))
 		} else {
-			t, err := template.ParseFiles("./templates/edit_links.gohtml")
+			suggestions, err := suggester.Suggest(cell, linkSuggestions)
+			if err != nil {
+				log.Printf("Error when suggesting links: %s", err)
+			}
+			t, err := parseTemplate(r, "edit_links.gohtml")
 			if err != nil {
 				log.Printf("Error when displaying links: %s", err)
 			}
-			err = t.Execute(w, cell)
+			type data struct {
+				models.Cell
+				Suggestions []models.Cell
+			}
+			err = t.Execute(w, data{Cell: cell, Suggestions: suggestions})
 			if err != nil {
 				log.Printf("Error when displaying links: %s", err)
 			}
@@ -166,60 +228,54 @@ func LinksHandler(lob repository.LobRepository, store *sessions.CookieStore) fun
 
 func LinkCellsHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		cellA := mux.Vars(r)["id"]
 		cellB := r.PostFormValue("cellToLink")
 		err := lob.LinkCells(cellA, cellB)
 		if err != nil {
 			log.Printf("Error when linking cells: %s", err)
 		}
-		http.Redirect(w, r, "/cell/"+cellA+"/links", http.StatusFound)
+		redirect(w, r, redirectTarget(r, "/cell/"+cellA+"/links"), http.StatusFound)
 	})
 }
 
 func UnlinkCellsHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		cellA := mux.Vars(r)["id"]
 		cellB := r.PostFormValue("cellToUnlink")
 		err := lob.UnlinkCells(cellA, cellB)
 		if err != nil {
 			log.Printf("Error when linking cells: %s", err)
 		}
-		http.Redirect(w, r, "/cell/"+cellA+"/links", http.StatusFound)
+		redirect(w, r, "/cell/"+cellA+"/links", http.StatusFound)
 	})
 }
 
-func checkAuthorization(w http.ResponseWriter, r *http.Request, store *sessions.CookieStore) (bool, error) {
-	//store == nil is for testing purposes
-	if store == nil {
-		return true, nil
+//returns the page a form asked to go back to, as long as it is in the labyrinth;
+//browsers read a backslash as a slash, so /\host is refused as //host is
+func redirectTarget(r *http.Request, fallback string) string {
+	target := r.PostFormValue("redirect")
+	if strings.Contains(target, "\\") {
+		return fallback
 	}
-	
-	session, err := store.Get(r, "lob-session")
-	if err != nil {
-		return false, err
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" ||
+		!strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") {
+		return fallback
 	}
-	auth := session.Values["authenticated"]
-	if auth == nil {
-		return false, nil
-	}
-	if !auth.(bool) {
-		session.AddFlash("You don't have access!")
-		err = session.Save(r, w)
-		if err != nil {
-			return false, err
-		}
-		return false, nil
-	}
-	return true, nil
+	return target
 }
 
 func SaveHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {		
+		lob := repository.FromContext(r.Context(), lob)
 		//parse the form and create the cell
 		updateCell := models.Cell{Id: r.PostFormValue("cellId"),
 			Title: r.PostFormValue("title"),
 			Body: r.PostFormValue("body"),
-			Room: r.PostFormValue("room")}
+			Room: r.PostFormValue("room"),
+			Private: r.PostFormValue("private") != ""}
 		//call repository to create it
 		_, err := lob.UpdateCell(updateCell)
 		if err != nil {
@@ -228,17 +284,19 @@ func SaveHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *ht
 			fmt.Fprintf(w, "Error when updating card: %s", err)
 		} 
 		//redirect to view the new cell card
-		http.Redirect(w, r, "/cell/"+r.PostFormValue("cellId"), http.StatusFound)
+		redirect(w, r, "/cell/"+r.PostFormValue("cellId"), http.StatusFound)
 	})
 }
 
 func CreateHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		//parse the form and create the cell
 		log.Printf("New cell title: %s", r.PostFormValue("title"))
 		newCell := models.Cell{Title: r.PostFormValue("title"),
 			Body: r.PostFormValue("body"),
-			Room: r.PostFormValue("room")}
+			Room: r.PostFormValue("room"),
+			Private: r.PostFormValue("private") != ""}
 		//call repository to create it
 		newCellId, err := lob.NewCell(newCell)
 		if err != nil {
@@ -247,14 +305,21 @@ func CreateHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *
 			fmt.Fprintf(w, "Error when creating card: %s", err)
 		} 
 		//redirect to view the new cell card
-		http.Redirect(w, r, "/cell/"+newCellId, http.StatusFound)
+		redirect(w, r, "/cell/"+newCellId, http.StatusFound)
+	})
+}
+
+//EntryHandler takes visitors to the entry cell of the labyrinth
+func EntryHandler() func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirect(w, r, "/cell/"+currentLabyrinth(r).Entry, http.StatusFound)
 	})
 }
 
 func PageHandler() func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		pageName := mux.Vars(r)["page"]
-		page, err := ioutil.ReadFile("./templates/" + pageName)
+		page, err := parseTemplate(r, pageName)
 		if err != nil {
 			notFound, err := ioutil.ReadFile("./templates/card_not_found.html")
 			if err != nil {
@@ -262,45 +327,73 @@ func PageHandler() func(w http.ResponseWriter, r *http.Request) {
 			}
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(w, string(notFound))
+			return
 		}
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, string(page))
+		err = page.Execute(w, nil)
+		if err != nil {
+			log.Printf("Error when returning page: %s", err)
+		}
 	})
 }
 
 func SearchSourcesHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		term := r.FormValue("term")
-		sources := lob.SearchSources(term)
-		returnString := "["
-		for _, source := range sources {
-			returnString += `"` + source.String() + `",`
+		sources := []string{}
+		for _, source := range lob.SearchSources(term) {
+			sources = append(sources, source.String())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(sources)
+		if err != nil {
+			log.Printf("Error when returning sources: %s", err)
 		}
-		returnString = returnString[:len(returnString)-1] + "]"
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, returnString)
 	})
 }
 
 func SearchRoomsHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		term := r.FormValue("term")
 		rooms := lob.SearchRooms(term)
-		returnString := "["
-		for _, room := range rooms {
-			returnString += `"` + room + `",`
+		if rooms == nil {
+			rooms = []string{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(rooms)
+		if err != nil {
+			log.Printf("Error when returning rooms: %s", err)
 		}
-		returnString = returnString[:len(returnString)-1] + "]"
+	})
+}
+
+func SearchTagsHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		term := r.FormValue("term")
+		tags := []string{}
+		for _, tag := range lob.SearchTags(term) {
+			tags = append(tags, tag.String())
+		}
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, returnString)
+		err := json.NewEncoder(w).Encode(tags)
+		if err != nil {
+			log.Printf("Error when returning search result: %s", err)
+		}
 	})
 }
 
 func SearchCellsHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		term := r.FormValue("term")
 		log.Printf("Searching for cells with %s", term)
-		cells := lob.SearchCells(term)
+		//every tag passed as ?tag= must be on the cell
+		r.ParseForm()
+		cells := lob.SearchCellsWithTags(term, r.Form["tag"])
 		type CellLinkAlias struct {
 			Id   string `json:"value"`
 			Text string `json:"label"`
@@ -320,9 +413,28 @@ func SearchCellsHandler(lob repository.LobRepository) func(w http.ResponseWriter
 }
 
 
+//RoomVisibilityHandler makes a room private or public again
+func RoomVisibilityHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		room := mux.Vars(r)["room"]
+		err := lob.SetRoomPrivate(room, r.PostFormValue("private") != "")
+		if err != nil {
+			log.Printf("Error when changing the visibility of room %s: %s", room, err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		redirect(w, r, "/rooms", http.StatusFound)
+	})
+}
+
 func RoomListHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		rooms, err := lob.ListRooms()
+		if represent(w, r, err, func() interface{} { return api.RoomsOf(rooms) }, func() string { return roomsMarkdown(r, rooms) }) {
+			return
+		}
 		if err != nil {
 			log.Printf("Error when obtaining the list of rooms: %s", err)
 			notFound, err := ioutil.ReadFile("./templates/card_not_found.html")
@@ -332,7 +444,7 @@ func RoomListHandler(lob repository.LobRepository) func(w http.ResponseWriter, r
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(w, string(notFound))
 		} else {
-			t, err := template.ParseFiles("./templates/rooms.gohtml")
+			t, err := parseTemplate(r, "rooms.gohtml")
 			if err != nil {
 				log.Printf("Error when parsing the rooms template: %s", err)
 			}
@@ -350,8 +462,12 @@ func RoomListHandler(lob repository.LobRepository) func(w http.ResponseWriter, r
 
 func RoomHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
 		room := mux.Vars(r)["room"]
 		cells, err := lob.ListCellsInRoom(room)
+		if represent(w, r, err, func() interface{} { return api.Summarize(cells) }, func() string { return cellsMarkdown(r, room, cells) }) {
+			return
+		}
 		if err != nil {
 			log.Printf("Error when entering room: %s", err)
 			notFound, err := ioutil.ReadFile("./templates/card_not_found.html")
@@ -361,19 +477,333 @@ func RoomHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *ht
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprint(w, string(notFound))
 		} else {
-			t, err := template.ParseFiles("./templates/cells_collection.gohtml")
+			t, err := parseTemplate(r, "cells_collection.gohtml")
 			if err != nil {
 				log.Printf("Error when parsing the room template: %s", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
 			}
 			type data struct {
+				Kind	string
 				Name	string
 				Cells 	[]models.Cell
 			}
-			roomCells := data{Name: room, Cells: cells}
+			roomCells := data{Kind: "Room", Name: room, Cells: cells}
 			err = t.Execute(w, roomCells)
 			if err != nil {
 				log.Printf("Error when returning card: %s", err)
 			}
 		}
 	})
-}
\ No newline at end of file
+}
+func TagHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		tag := mux.Vars(r)["tag"]
+		cells, err := lob.ListCellsWithTag(tag)
+		if err != nil {
+			log.Printf("Error when listing tag: %s", err)
+			notFound, err := ioutil.ReadFile("./templates/card_not_found.html")
+			if err != nil {
+				log.Printf("Error when listing tag: %s", err)
+			}
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, string(notFound))
+		} else {
+			t, err := parseTemplate(r, "cells_collection.gohtml")
+			if err != nil {
+				log.Printf("Error when parsing the tag template: %s", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			type data struct {
+				Kind  string
+				Name  string
+				Cells []models.Cell
+			}
+			tagCells := data{Kind: "Tag", Name: tag, Cells: cells}
+			err = t.Execute(w, tagCells)
+			if err != nil {
+				log.Printf("Error when returning tag: %s", err)
+			}
+		}
+	})
+}
+
+//the deepest neighbourhood we are willing to walk in one request
+const maxNeighbourhoodDepth = 5
+
+func neighbourhoodDepth(r *http.Request) int {
+	depth, err := strconv.Atoi(r.FormValue("depth"))
+	if err != nil || depth < 1 {
+		return 2
+	}
+	if depth > maxNeighbourhoodDepth {
+		return maxNeighbourhoodDepth
+	}
+	return depth
+}
+
+func NeighbourhoodHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		cellId := mux.Vars(r)["id"]
+		neighbourhood, err := lob.GetNeighbourhood(cellId, neighbourhoodDepth(r))
+		if err != nil {
+			log.Printf("Error when returning neighbourhood: %s", err)
+			notFound, err := ioutil.ReadFile("./templates/card_not_found.html")
+			if err != nil {
+				log.Printf("Error when returning neighbourhood: %s", err)
+			}
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, string(notFound))
+		} else {
+			t, err := parseTemplate(r, "neighbourhood.gohtml")
+			if err != nil {
+				log.Printf("Error when parsing the neighbourhood template: %s", err)
+			}
+			err = t.Execute(w, neighbourhood)
+			if err != nil {
+				log.Printf("Error when returning neighbourhood: %s", err)
+			}
+		}
+	})
+}
+
+func NeighbourhoodJSONHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		cellId := mux.Vars(r)["id"]
+		neighbourhood, err := lob.GetNeighbourhood(cellId, neighbourhoodDepth(r))
+		if err != nil {
+			log.Printf("Error when returning neighbourhood: %s", err)
+			http.Error(w, "Cell not found", http.StatusNotFound)
+			return
+		}
+		type CellAlias struct {
+			Id       string `json:"id"`
+			Title    string `json:"title"`
+			Room     string `json:"room"`
+			Summary  string `json:"summary"`
+			Distance int    `json:"distance"`
+		}
+		type LinkAlias struct {
+			CellA string `json:"source"`
+			CellB string `json:"target"`
+		}
+		type NeighbourhoodAlias struct {
+			Center CellAlias   `json:"center"`
+			Depth  int         `json:"depth"`
+			Cells  []CellAlias `json:"cells"`
+			Links  []LinkAlias `json:"links"`
+		}
+		center := neighbourhood.Center
+		alias := NeighbourhoodAlias{
+			Center: CellAlias{Id: center.Id, Title: center.Title, Room: center.Room, Summary: center.Summary()},
+			Depth:  neighbourhood.Depth,
+			Cells:  []CellAlias{},
+			Links:  []LinkAlias{},
+		}
+		for _, neighbour := range neighbourhood.Cells {
+			cell := neighbour.Cell
+			alias.Cells = append(alias.Cells, CellAlias{Id: cell.Id, Title: cell.Title, Room: cell.Room, Summary: cell.Summary(), Distance: neighbour.Distance})
+		}
+		for _, link := range neighbourhood.Links {
+			alias.Links = append(alias.Links, LinkAlias{CellA: link.CellA, CellB: link.CellB})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(alias)
+		if err != nil {
+			log.Printf("Error when returning neighbourhood: %s", err)
+		}
+	})
+}
+
+func OrphansHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		report, err := lob.ListOrphanCells(r.FormValue("room"))
+		if err != nil {
+			log.Printf("Error when obtaining the orphans report: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "Error when obtaining the orphans report: %s", err)
+			return
+		}
+		t, err := parseTemplate(r, "orphans.gohtml")
+		if err != nil {
+			log.Printf("Error when parsing the orphans template: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err = t.Execute(w, report)
+		if err != nil {
+			log.Printf("Error when returning the orphans report: %s", err)
+		}
+	})
+}
+
+//the number of most central cells shown in the insights page
+const insightsTopCells = 20
+
+func InsightsHandler(analyzer *graph.Analyzer) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		analysis, err := analyzer.Analysis()
+		if err != nil {
+			log.Printf("Error when analyzing the labyrinth: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "Error when analyzing the labyrinth: %s", err)
+			return
+		}
+		t, err := parseTemplate(r, "insights.gohtml")
+		if err != nil {
+			log.Printf("Error when parsing the insights template: %s", err)
+		}
+		type data struct {
+			Central   []graph.CellScore
+			Islands   [][]models.Cell
+			Bridges   []graph.RoomBridge
+			CellCount int
+			LinkCount int
+			Computed  string
+		}
+		insights := data{
+			Central:   analysis.Top(insightsTopCells),
+			Islands:   analysis.Islands,
+			Bridges:   analysis.Bridges,
+			CellCount: len(analysis.Cells),
+			LinkCount: len(analysis.Graph.Links()),
+			Computed:  analysis.Computed.Format("02 Jan 2006 15:04"),
+		}
+		err = t.Execute(w, insights)
+		if err != nil {
+			log.Printf("Error when returning insights: %s", err)
+		}
+	})
+}
+
+func ExportHandler(analyzer *graph.Analyzer) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format, ok := graph.Formats[mux.Vars(r)["format"]]
+		if !ok {
+			http.Error(w, "Unknown export format", http.StatusNotFound)
+			return
+		}
+		analysis, err := analyzer.Analysis()
+		if err != nil {
+			log.Printf("Error when exporting the labyrinth: %s", err)
+			http.Error(w, "Error when exporting the labyrinth", http.StatusInternalServerError)
+			return
+		}
+		g := analysis.Graph.Restrict(r.FormValue("room"), r.FormValue("cell"), neighbourhoodDepth(r))
+		w.Header().Set("Content-Type", format.ContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="labyrinth.`+format.Extension+`"`)
+		err = format.Write(w, g)
+		if err != nil {
+			log.Printf("Error when exporting the labyrinth: %s", err)
+		}
+	})
+}
+
+//downloads the labyrinth as a zipped vault of Markdown notes
+func VaultHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		var zipped bytes.Buffer
+		if err := vault.Export(lob, &zipped); err != nil {
+			log.Printf("Error when exporting the vault: %s", err)
+			http.Error(w, "Error when exporting the labyrinth", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="labyrinth-vault.zip"`)
+		if _, err := zipped.WriteTo(w); err != nil {
+			log.Printf("Error when exporting the vault: %s", err)
+		}
+	})
+}
+
+func GraphHandler() func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, err := parseTemplate(r, "graph.gohtml")
+		if err != nil {
+			log.Printf("Error when parsing the graph template: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		type data struct {
+			CellId string
+			Room   string
+			Depth  int
+		}
+		graphData := data{CellId: mux.Vars(r)["id"], Room: r.FormValue("room"), Depth: neighbourhoodDepth(r)}
+		err = t.Execute(w, graphData)
+		if err != nil {
+			log.Printf("Error when returning graph: %s", err)
+		}
+	})
+}
+
+func GraphJSONHandler(analyzer *graph.Analyzer) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		analysis, err := analyzer.Analysis()
+		if err != nil {
+			log.Printf("Error when returning graph: %s", err)
+			http.Error(w, "Error when returning graph", http.StatusInternalServerError)
+			return
+		}
+		cellId := r.FormValue("cell")
+		if _, ok := analysis.Graph.Cell(cellId); cellId != "" && !ok {
+			http.Error(w, "Cell not found", http.StatusNotFound)
+			return
+		}
+		g := analysis.Graph.Restrict(r.FormValue("room"), cellId, neighbourhoodDepth(r))
+		type NodeAlias struct {
+			Id     string `json:"id"`
+			Label  string `json:"label"`
+			Room   string `json:"room"`
+			Degree int    `json:"degree"`
+		}
+		type LinkAlias struct {
+			CellA string `json:"source"`
+			CellB string `json:"target"`
+		}
+		type GraphAlias struct {
+			Nodes []NodeAlias `json:"nodes"`
+			Links []LinkAlias `json:"links"`
+		}
+		alias := GraphAlias{Nodes: []NodeAlias{}, Links: []LinkAlias{}}
+		for _, cell := range g.Cells() {
+			alias.Nodes = append(alias.Nodes, NodeAlias{Id: cell.Id, Label: cell.Summary(), Room: cell.Room, Degree: analysis.Graph.Degree(cell.Id)})
+		}
+		for _, link := range g.Links() {
+			alias.Links = append(alias.Links, LinkAlias{CellA: link.CellA, CellB: link.CellB})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(alias)
+		if err != nil {
+			log.Printf("Error when returning graph: %s", err)
+		}
+	})
+}
+
+//resolves [[Cell Title]] links to the cell with that title
+func WikiHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		title := mux.Vars(r)["title"]
+		cell, err := lob.GetCellByTitle(title)
+		if err != nil {
+			log.Printf("Error when following wiki link to %s: %s", title, err)
+			notFound, err := ioutil.ReadFile("./templates/card_not_found.html")
+			if err != nil {
+				log.Printf("Error when following wiki link: %s", err)
+			}
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, string(notFound))
+			return
+		}
+		redirect(w, r, "/cell/"+cell.Id, http.StatusFound)
+	})
+}
@@ -12,14 +12,21 @@ import (
 	"log"
 	"database/sql"
 	"io/ioutil"
+	"regexp"
+	"time"
 	
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
 	
 	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/dacero/labyrinth-of-babel/api"
 	"github.com/dacero/labyrinth-of-babel/handlers"
 	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/ratelimit"
+	"github.com/dacero/labyrinth-of-babel/graph"
+	"github.com/dacero/labyrinth-of-babel/similarity"
 )
 
 func resetDB() {
@@ -71,17 +78,31 @@ var _ = Describe("Handler", func() {
 		rr = httptest.NewRecorder()
 		router = mux.NewRouter()
 		router.HandleFunc("/cell/{id}", handlers.ViewHandler(lobRepository))
-		router.HandleFunc("/cell/{id}/edit", handlers.EditHandler(lobRepository, nil))
-		router.HandleFunc("/cell/{id}/edit/sources", handlers.SourcesHandler(lobRepository, nil))
+		router.HandleFunc("/cell/{id}/edit", handlers.EditHandler(lobRepository))
+		router.HandleFunc("/cell/{id}/edit/sources", handlers.SourcesHandler(lobRepository))
+		router.HandleFunc("/cell/{id}/links", handlers.LinksHandler(lobRepository, similarity.NewEngine(lobRepository)))
 		router.HandleFunc("/cell/{id}/addSource", handlers.AddSourceHandler(lobRepository)).Methods("POST") //addSource
 		router.HandleFunc("/cell/{id}/removeSource", handlers.RemoveSourceHandler(lobRepository)).Methods("POST") //removeSource
 		router.HandleFunc("/cell/{id}/linkCell", handlers.LinkCellsHandler(lobRepository)).Methods("POST")
 		router.HandleFunc("/cell/{id}/unlinkCell", handlers.UnlinkCellsHandler(lobRepository)).Methods("POST")
+		router.HandleFunc("/cell/{id}/neighbourhood", handlers.NeighbourhoodHandler(lobRepository))
+		router.HandleFunc("/cell/{id}/neighbourhood.json", handlers.NeighbourhoodJSONHandler(lobRepository))
 		router.HandleFunc("/save", handlers.SaveHandler(lobRepository))
 		router.HandleFunc("/new", handlers.CreateHandler(lobRepository))
 		router.HandleFunc("/sources", handlers.SearchSourcesHandler(lobRepository))
 		router.HandleFunc("/rooms", handlers.SearchRoomsHandler(lobRepository))
 		router.HandleFunc("/page/{page}", handlers.PageHandler())
+		router.HandleFunc("/reports/orphans", handlers.OrphansHandler(lobRepository))
+		router.HandleFunc("/cell/{id}/addTag", handlers.AddTagHandler(lobRepository)).Methods("POST")
+		router.HandleFunc("/cell/{id}/removeTag", handlers.RemoveTagHandler(lobRepository)).Methods("POST")
+		router.HandleFunc("/tag/{tag:.+}", handlers.TagHandler(lobRepository))
+		router.HandleFunc("/searchTags", handlers.SearchTagsHandler(lobRepository))
+		router.HandleFunc("/searchCells", handlers.SearchCellsHandler(lobRepository))
+		router.HandleFunc("/insights", handlers.InsightsHandler(graph.NewAnalyzer(lobRepository)))
+		router.HandleFunc("/export/{format}", handlers.ExportHandler(graph.NewAnalyzer(lobRepository)))
+		router.HandleFunc("/graph.json", handlers.GraphJSONHandler(graph.NewAnalyzer(lobRepository)))
+		router.HandleFunc("/graph", handlers.GraphHandler())
+		router.HandleFunc("/cell/{id}/graph", handlers.GraphHandler())
 	})
 
 	Describe("Viewing a card", func() {
@@ -312,6 +333,17 @@ var _ = Describe("Handler", func() {
 		})
 	})
 	
+	Describe("When editing the links of a cell", func() {
+		BeforeEach(func() {
+			req, err := http.NewRequest("GET", "http://localhost:8080/cell/"+cellId+"/links", nil)
+			Expect(err).To(BeNil())
+			router.ServeHTTP(rr, req)
+		})
+		It("should return Status OK", func() {
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+	})
+	
 	Describe("When linking a cell", func() {
 		Context("given I provide a proper cell", func() {
 			BeforeEach(func() {
@@ -328,6 +360,35 @@ var _ = Describe("Handler", func() {
 		})
 	})
 	
+	Describe("When linking an unlinked mention from a card", func() {
+		BeforeEach(func() {
+			form := url.Values{}
+			form.Add("cellToLink", "417ecfe7-d2b4-4e43-afd4-dbf5f431d97d")
+			form.Add("redirect", "/cell/df38bd04-0ec4-41bf-9e53-d0eeb95a4939")
+			req, err := http.NewRequest("POST", "http://localhost:8080/cell/df38bd04-0ec4-41bf-9e53-d0eeb95a4939/linkCell", strings.NewReader(form.Encode()))
+			Expect(err).To(BeNil())
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			router.ServeHTTP(rr, req)
+		})
+		It("should go back to the card", func() {
+			Expect(rr.Code).To(Equal(http.StatusFound))
+			Expect(rr.Header().Get("Location")).To(Equal("/cell/df38bd04-0ec4-41bf-9e53-d0eeb95a4939"))
+		})
+		It("should not go back outside the labyrinth", func() {
+			for _, target := range []string{"//evil.com", `/\evil.com`, `\/evil.com`, "https://evil.com/", "/\t/evil.com"} {
+				form := url.Values{}
+				form.Add("cellToLink", "thiscelldoesnotexist")
+				form.Add("redirect", target)
+				req, err := http.NewRequest("POST", "http://localhost:8080/cell/df38bd04-0ec4-41bf-9e53-d0eeb95a4939/linkCell", strings.NewReader(form.Encode()))
+				Expect(err).To(BeNil())
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				rr = httptest.NewRecorder()
+				router.ServeHTTP(rr, req)
+				Expect(rr.Header().Get("Location")).To(Equal("/cell/df38bd04-0ec4-41bf-9e53-d0eeb95a4939/links"), target)
+			}
+		})
+	})
+
 	Describe("When unlinking two cells", func() {
 		Context("given I provide a proper cell", func() {
 			BeforeEach(func() {
@@ -344,6 +405,918 @@ var _ = Describe("Handler", func() {
 		})
 	})
 
+	
+	Describe("When exploring the neighbourhood of a cell", func() {
+		Context("given the cell exists", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/cell/"+cellId+"/neighbourhood.json?depth=2", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should return a proper json", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				var neighbourhood struct {
+					Depth int
+					Cells []struct {
+						Id       string
+						Distance int
+					}
+				}
+				err = json.Unmarshal(rr.Body.Bytes(), &neighbourhood)
+				Expect(err).To(BeNil())
+				Expect(neighbourhood.Depth).To(Equal(2))
+				for _, cell := range neighbourhood.Cells {
+					Expect(cell.Distance).To(BeNumerically(">=", 1))
+					Expect(cell.Distance).To(BeNumerically("<=", 2))
+				}
+			})
+		})
+		Context("given the cell does not exist", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/cell/thiscelldoesnotexist/neighbourhood", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should return NOT FOUND error", func() {
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("When reporting orphan cells", func() {
+		Context("filtering by room", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/reports/orphans?room=This+is+a+room", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should return Status OK", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+		Context("filtering by a room with markup", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/reports/orphans?room=%3Cscript%3Ealert(1)%3C/script%3E", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should escape the room", func() {
+				Expect(rr.Body.String()).ToNot(ContainSubstring("<script>alert(1)"))
+				Expect(rr.Body.String()).To(ContainSubstring("&lt;script&gt;alert(1)"))
+			})
+		})
+	})
+
+	Describe("When looking at the labyrinth insights", func() {
+		BeforeEach(func() {
+			req, err := http.NewRequest("GET", "http://localhost:8080/insights", nil)
+			Expect(err).To(BeNil())
+			router.ServeHTTP(rr, req)
+		})
+		It("should return Status OK", func() {
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("When exporting the labyrinth", func() {
+		Context("in a known format", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/export/gexf?room=This+is+a+room", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should return the file", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(rr.Header().Get("Content-Type")).To(Equal("application/gexf+xml"))
+			})
+		})
+		Context("in an unknown format", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/export/pdf", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should return NOT FOUND error", func() {
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("When drawing the graph of the labyrinth", func() {
+		Context("around a cell that exists", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/graph.json?cell="+cellId+"&depth=1", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should return the nodes and links around it", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				var graphData struct {
+					Nodes []struct {
+						Id string `json:"id"`
+					} `json:"nodes"`
+					Links []struct {
+						Source string `json:"source"`
+					} `json:"links"`
+				}
+				err = json.Unmarshal(rr.Body.Bytes(), &graphData)
+				Expect(err).To(BeNil())
+				Expect(len(graphData.Nodes)).To(BeNumerically(">", 0))
+			})
+		})
+		Context("around a cell that does not exist", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/graph.json?cell=thiscelldoesnotexist", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should return NOT FOUND error", func() {
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+		Context("of a room with markup", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/graph?room=%3Cscript%3Ealert(1)%3C/script%3E", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should escape the room", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(rr.Body.String()).ToNot(ContainSubstring("<script>alert(1)"))
+				Expect(rr.Body.String()).To(ContainSubstring("<h1>&lt;script&gt;alert(1)&lt;/script&gt;</h1>"))
+			})
+		})
+		Context("around a cell id with markup", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", `http://localhost:8080/cell/%22%3E%3Cscript%3Ealert(1)/graph`, nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should escape the cell id", func() {
+				Expect(rr.Body.String()).ToNot(ContainSubstring("<script>alert(1)"))
+			})
+		})
+	})
+
+	Describe("When tagging cells", func() {
+		Context("given I add a tag", func() {
+			BeforeEach(func() {
+				form := url.Values{}
+				form.Add("tag", "Labyrinths")
+				req, err := http.NewRequest("POST", "http://localhost:8080/cell/"+cellId+"/addTag", strings.NewReader(form.Encode()))
+				Expect(err).To(BeNil())
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				router.ServeHTTP(rr, req)
+			})
+			It("should go back to editing the cell", func() {
+				Expect(rr.Code).To(Equal(http.StatusFound))
+				Expect(rr.Header().Get("Location")).To(Equal("/cell/" + cellId + "/edit"))
+			})
+		})
+		Context("given I browse a tag", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/tag/Borges", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should return Status OK", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+		Context("given a tag with spaces, slashes and question marks", func() {
+			BeforeEach(func() {
+				form := url.Values{}
+				form.Add("tag", "Tlön / Uqbar?")
+				req, err := http.NewRequest("POST", "http://localhost:8080/cell/"+cellId+"/addTag", strings.NewReader(form.Encode()))
+				Expect(err).To(BeNil())
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				router.ServeHTTP(rr, req)
+				rr = httptest.NewRecorder()
+			})
+			It("should link the card to the page of the tag", func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/cell/"+cellId, nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+				Expect(rr.Body.String()).To(ContainSubstring(`href="/tag/Tl%C3%B6n%20%2F%20Uqbar%3F"`))
+				rr = httptest.NewRecorder()
+				req, err = http.NewRequest("GET", "http://localhost:8080/tag/Tl%C3%B6n%20%2F%20Uqbar%3F", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(rr.Body.String()).To(ContainSubstring(cellId))
+			})
+		})
+		Context("given I browse a tag with markup", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/tag/%3Cscript%3Ealert(1)%3C%2Fscript%3E", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should escape it", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(rr.Body.String()).To(ContainSubstring("&lt;script&gt;alert(1)&lt;/script&gt;"))
+				Expect(rr.Body.String()).NotTo(ContainSubstring("<script>"))
+			})
+		})
+		Context("given I search for cells with a tag", func() {
+			BeforeEach(func() {
+				req, err := http.NewRequest("GET", "http://localhost:8080/searchCells?term=&tag=Borges", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should only return tagged cells", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				var cells []struct {
+					Value string `json:"value"`
+				}
+				err = json.Unmarshal(rr.Body.Bytes(), &cells)
+				Expect(err).To(BeNil())
+				Expect(len(cells)).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("Logging in", func() {
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			router.HandleFunc("/login", handlers.LoginHandler(lobRepository, sessions.NewCookieStore([]byte("a test key")))).Methods("GET", "POST")
+		})
+		Context("given I ask for the login page", func() {
+			BeforeEach(func() {
+				req, err = http.NewRequest("GET", "http://localhost:8080/login?redirect=/cell/"+cellId, nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should show the form", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(rr.Body.String()).To(ContainSubstring(`name="password"`))
+				Expect(rr.Body.String()).To(ContainSubstring(`value="/cell/` + cellId + `"`))
+			})
+		})
+		Context("given I log in with the right password", func() {
+			BeforeEach(func() {
+				form := url.Values{"username": {"borges"}, "password": {"labyrinth"}, "redirect": {"/cell/" + cellId}}
+				req, err = http.NewRequest("POST", "http://localhost:8080/login", strings.NewReader(form.Encode()))
+				Expect(err).To(BeNil())
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				router.ServeHTTP(rr, req)
+			})
+			It("should open a session and take me back", func() {
+				Expect(rr.Code).To(Equal(http.StatusFound))
+				Expect(rr.Header().Get("Location")).To(Equal("/cell/" + cellId))
+				Expect(rr.Header().Get("Set-Cookie")).To(ContainSubstring("lob-session="))
+			})
+		})
+		Context("given I log in with the wrong password", func() {
+			BeforeEach(func() {
+				form := url.Values{"username": {"borges"}, "password": {"minotaur"}}
+				req, err = http.NewRequest("POST", "http://localhost:8080/login", strings.NewReader(form.Encode()))
+				Expect(err).To(BeNil())
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				router.ServeHTTP(rr, req)
+			})
+			It("should refuse me", func() {
+				Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+				Expect(rr.Header().Get("Set-Cookie")).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Enforcing roles", func() {
+		var cookies []*http.Cookie
+		serve := func(method string, path string) {
+			req, err = http.NewRequest(method, "http://localhost:8080"+path, nil)
+			Expect(err).To(BeNil())
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			router.ServeHTTP(rr, req)
+		}
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			handlers.RegisterRoutes(router, handlers.Labyrinth{
+				Entry: cellId,
+				Lob:   lobRepository,
+				Store: sessions.NewCookieStore([]byte("a test key")),
+			})
+			cookies = nil
+		})
+		Context("given I have not logged in", func() {
+			It("should let me view cells", func() {
+				serve("GET", "/cell/"+cellId)
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+			It("should send me to log in before editing", func() {
+				serve("GET", "/cell/"+cellId+"/edit")
+				Expect(rr.Code).To(Equal(http.StatusFound))
+				Expect(rr.Header().Get("Location")).To(Equal("/login?redirect=" + url.QueryEscape("/cell/"+cellId+"/edit")))
+			})
+		})
+		Context("given I am a reader", func() {
+			BeforeEach(func() {
+				cookies = loginAs(router, "funes")
+			})
+			It("should forbid me to edit", func() {
+				serve("GET", "/cell/"+cellId+"/edit")
+				Expect(rr.Code).To(Equal(http.StatusForbidden))
+			})
+		})
+		Context("given I am an editor", func() {
+			BeforeEach(func() {
+				cookies = loginAs(router, "borges")
+			})
+			It("should let me edit", func() {
+				serve("GET", "/cell/"+cellId+"/edit")
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+			It("should forbid me to manage users", func() {
+				serve("GET", "/admin/users")
+				Expect(rr.Code).To(Equal(http.StatusForbidden))
+			})
+		})
+		Context("given I am an admin", func() {
+			BeforeEach(func() {
+				cookies = loginAs(router, "ireneo")
+			})
+			It("should let me manage users", func() {
+				serve("GET", "/admin/users")
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(rr.Body.String()).To(ContainSubstring("funes"))
+			})
+		})
+	})
+
+	Describe("Protecting every route", func() {
+		var readerCookies []*http.Cookie
+		serve := func(method string, path string, accept string, cookies []*http.Cookie) {
+			req, err = http.NewRequest(method, "http://localhost:8080"+path, nil)
+			Expect(err).To(BeNil())
+			if accept != "" {
+				req.Header.Set("Accept", accept)
+			}
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			rr = httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+		}
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			handlers.RegisterRoutes(router, handlers.Labyrinth{
+				Entry: cellId,
+				Lob:   lobRepository,
+				Store: sessions.NewCookieStore([]byte("a test key")),
+			})
+			readerCookies = loginAs(router, "funes")
+		})
+		for _, route := range handlers.Routes(handlers.Labyrinth{}) {
+			route := route
+			methods := route.Methods
+			if len(methods) == 0 {
+				methods = []string{"GET", "POST"}
+			}
+			for _, method := range methods {
+				method := method
+				path := examplePath(route, cellId)
+				if route.Role == models.RoleAnyone {
+					//writes need a form token, see Refusing forged forms
+					if method != "GET" {
+						continue
+					}
+					It("should let anyone "+method+" "+route.Path, func() {
+						serve(method, path, "", nil)
+						Expect(rr.Code).NotTo(Equal(http.StatusUnauthorized))
+						Expect(rr.Code).NotTo(Equal(http.StatusForbidden))
+						Expect(rr.Header().Get("Location")).NotTo(HavePrefix("/login"))
+					})
+					continue
+				}
+				//the API only has API clients
+				if !strings.HasPrefix(route.Path, "/api/") {
+					It("should send browsers to log in before they "+method+" "+route.Path, func() {
+						serve(method, path, "text/html", nil)
+						Expect(rr.Code).To(Equal(http.StatusFound))
+						Expect(rr.Header().Get("Location")).To(HavePrefix("/login"))
+					})
+				}
+				It("should answer 401 to API clients that "+method+" "+route.Path+" without logging in", func() {
+					serve(method, path, "application/json", nil)
+					Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+					Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+				})
+				if models.RoleReader.Allows(route.Role) {
+					continue
+				}
+				It("should answer 403 to readers that "+method+" "+route.Path, func() {
+					serve(method, path, "application/json", readerCookies)
+					Expect(rr.Code).To(Equal(http.StatusForbidden))
+				})
+			}
+		}
+		It("should only accept writes to the labyrinth from editors", func() {
+			for _, route := range handlers.Routes(handlers.Labyrinth{}) {
+				if route.Path == "/login" || route.Path == "/logout" || strings.HasPrefix(route.Path, "/account/") {
+					continue
+				}
+				for _, method := range route.Methods {
+					if method != "GET" {
+						Expect(route.Role.Allows(models.RoleEditor)).To(BeTrue(), route.Path)
+					}
+				}
+			}
+		})
+		It("should not change a cell when a visitor posts to it", func() {
+			form := url.Values{"cellId": {cellId}, "title": {"Defaced"}, "body": {"Defaced"}, "room": {"This is a room"}}
+			req, err = http.NewRequest("POST", "http://localhost:8080/save", strings.NewReader(form.Encode()))
+			Expect(err).To(BeNil())
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			router.ServeHTTP(rr, req)
+			Expect(rr.Code).To(Equal(http.StatusFound))
+			cell, err := lobRepository.GetCell(cellId)
+			Expect(err).To(BeNil())
+			Expect(cell.Title).NotTo(Equal("Defaced"))
+		})
+	})
+
+	Describe("Hiding private cells", func() {
+		var privateId string
+		BeforeEach(func() {
+			privateId, err = lobRepository.NewCell(models.Cell{Title: "Journal entry",
+				Body:    "Only for those who have logged in",
+				Room:    "This is a room",
+				Private: true})
+			Expect(err).To(BeNil())
+			router = mux.NewRouter()
+			handlers.RegisterRoutes(router, handlers.Labyrinth{
+				Entry:  cellId,
+				Lob:    lobRepository,
+				Public: lobRepository.Public(),
+				Store:  sessions.NewCookieStore([]byte("a test key")),
+			})
+		})
+		Context("given I have not logged in", func() {
+			It("should not find them", func() {
+				req, err = http.NewRequest("GET", "http://localhost:8080/cell/"+privateId, nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+		Context("given I have logged in", func() {
+			It("should show them with a marker", func() {
+				req, err = http.NewRequest("GET", "http://localhost:8080/cell/"+privateId, nil)
+				Expect(err).To(BeNil())
+				for _, cookie := range loginAs(router, "funes") {
+					req.AddCookie(cookie)
+				}
+				router.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(rr.Body.String()).To(ContainSubstring(`class="private-marker"`))
+			})
+		})
+	})
+
+	Describe("Using API tokens", func() {
+		var token string
+		createTokenFor := func(username string, scopes ...models.Scope) string {
+			user, err := lobRepository.GetUserByUsername(username)
+			Expect(err).To(BeNil())
+			secret, hash, err := models.GenerateToken()
+			Expect(err).To(BeNil())
+			_, err = lobRepository.NewAPIToken(models.APIToken{UserId: user.Id, Name: "editor plugin", Scopes: scopes}, hash)
+			Expect(err).To(BeNil())
+			return secret
+		}
+		createToken := func(scopes ...models.Scope) string {
+			return createTokenFor("borges", scopes...)
+		}
+		serveWithToken := func(method string, path string) {
+			req, err = http.NewRequest(method, "http://localhost:8080"+path, nil)
+			Expect(err).To(BeNil())
+			req.Header.Set("Authorization", "Bearer "+token)
+			router.ServeHTTP(rr, req)
+		}
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			handlers.RegisterRoutes(router, handlers.Labyrinth{
+				Entry: cellId,
+				Lob:   lobRepository,
+				Store: sessions.NewCookieStore([]byte("a test key")),
+			})
+		})
+		Context("given my token can write", func() {
+			BeforeEach(func() {
+				token = createToken(models.ScopeRead, models.ScopeWrite)
+			})
+			It("should let me edit", func() {
+				serveWithToken("GET", "/cell/"+cellId+"/edit")
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+		})
+		Context("given my token can only read", func() {
+			BeforeEach(func() {
+				token = createToken(models.ScopeRead)
+			})
+			It("should forbid me to edit", func() {
+				serveWithToken("GET", "/cell/"+cellId+"/edit")
+				Expect(rr.Code).To(Equal(http.StatusForbidden))
+			})
+			It("should forbid me to create a token that can write", func() {
+				before, err := lobRepository.ListAPITokens("0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21")
+				Expect(err).To(BeNil())
+				form := url.Values{"name": {"escalated"}, "scope": {"read", "write"}}
+				req, err = http.NewRequest("POST", "http://localhost:8080/account/tokens", strings.NewReader(form.Encode()))
+				Expect(err).To(BeNil())
+				req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+				req.Header.Set("Authorization", "Bearer "+token)
+				router.ServeHTTP(rr, req)
+				Expect(rr.Code).To(Equal(http.StatusForbidden))
+				after, err := lobRepository.ListAPITokens("0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21")
+				Expect(err).To(BeNil())
+				Expect(after).To(HaveLen(len(before)))
+			})
+		})
+		Context("given I delete a cell through the API", func() {
+			var doomedId string
+			BeforeEach(func() {
+				doomedId, err = lobRepository.NewCell(models.Cell{Title: "Doomed", Body: "Soon gone", Room: "Borges"})
+				Expect(err).To(BeNil())
+			})
+			It("should forbid editors", func() {
+				token = createTokenFor("borges", models.ScopeRead, models.ScopeWrite)
+				serveWithToken("DELETE", "/api/v1/cells/"+doomedId)
+				Expect(rr.Code).To(Equal(http.StatusForbidden))
+				_, err = lobRepository.GetCell(doomedId)
+				Expect(err).To(BeNil())
+			})
+			It("should let admins", func() {
+				token = createTokenFor("ireneo", models.ScopeRead, models.ScopeWrite)
+				serveWithToken("DELETE", "/api/v1/cells/"+doomedId)
+				Expect(rr.Code).To(Equal(http.StatusNoContent))
+				_, err = lobRepository.GetCell(doomedId)
+				Expect(err).NotTo(BeNil())
+			})
+		})
+		Context("given my token is not valid", func() {
+			BeforeEach(func() {
+				token = "lob_not-a-token"
+			})
+			It("should answer 401 even where no login is needed", func() {
+				serveWithToken("GET", "/cell/"+cellId)
+				Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			})
+		})
+	})
+
+	Describe("Refusing forged forms", func() {
+		var cookies []*http.Cookie
+		post := func(path string, form url.Values, header http.Header) {
+			req, err = http.NewRequest("POST", "http://localhost:8080"+path, strings.NewReader(form.Encode()))
+			Expect(err).To(BeNil())
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			for name, values := range header {
+				req.Header[name] = values
+			}
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			router.ServeHTTP(rr, req)
+		}
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			handlers.RegisterRoutes(router, handlers.Labyrinth{
+				Entry: cellId,
+				Lob:   lobRepository,
+				Store: sessions.NewCookieStore([]byte("a test key")),
+			})
+			cookies = loginAs(router, "borges")
+		})
+		It("should refuse a form without a token", func() {
+			post("/cell/"+cellId+"/addTag", url.Values{"tag": {"Forged"}}, nil)
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+			Expect(rr.Body.String()).To(ContainSubstring("The form expired"))
+		})
+		It("should refuse a form with another session's token", func() {
+			token, _ := formToken(router, nil)
+			post("/cell/"+cellId+"/addTag", url.Values{"tag": {"Forged"}, "csrf_token": {token}}, nil)
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+		It("should accept a form with the token of the session", func() {
+			token, _ := formToken(router, cookies)
+			post("/cell/"+cellId+"/addTag", url.Values{"tag": {"Genuine"}, "csrf_token": {token}}, nil)
+			Expect(rr.Code).To(Equal(http.StatusFound))
+		})
+		It("should accept the token in a header", func() {
+			token, _ := formToken(router, cookies)
+			post("/cell/"+cellId+"/addTag", url.Values{"tag": {"Genuine"}}, http.Header{"X-Csrf-Token": {token}})
+			Expect(rr.Code).To(Equal(http.StatusFound))
+		})
+		It("should not ask API tokens for one", func() {
+			secret, hash, err := models.GenerateToken()
+			Expect(err).To(BeNil())
+			_, err = lobRepository.NewAPIToken(models.APIToken{UserId: "0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21",
+				Name: "script", Scopes: []models.Scope{models.ScopeRead, models.ScopeWrite}}, hash)
+			Expect(err).To(BeNil())
+			cookies = nil
+			post("/cell/"+cellId+"/addTag", url.Values{"tag": {"Scripted"}}, http.Header{"Authorization": {"Bearer " + secret}})
+			Expect(rr.Code).To(Equal(http.StatusFound))
+		})
+	})
+
+	Describe("Ending sessions", func() {
+		var cookies []*http.Cookie
+		serve := func(method string, path string, form url.Values) {
+			req, err = http.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(form.Encode()))
+			Expect(err).To(BeNil())
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			rr = httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+		}
+		route := func(lifetime handlers.SessionLifetime) {
+			router = mux.NewRouter()
+			handlers.RegisterRoutes(router, handlers.Labyrinth{
+				Entry:    cellId,
+				Lob:      lobRepository,
+				Store:    sessions.NewCookieStore([]byte("a test key")),
+				Lifetime: lifetime,
+			})
+			cookies = loginAs(router, "borges")
+		}
+		Context("given my session has been idle for too long", func() {
+			BeforeEach(func() {
+				route(handlers.SessionLifetime{Idle: time.Nanosecond, Absolute: time.Hour})
+				time.Sleep(time.Millisecond)
+			})
+			It("should send me to log in again", func() {
+				serve("GET", "/cell/"+cellId+"/edit", nil)
+				Expect(rr.Code).To(Equal(http.StatusFound))
+				Expect(rr.Header().Get("Location")).To(HavePrefix("/login"))
+			})
+		})
+		Context("given my session has been open for too long", func() {
+			BeforeEach(func() {
+				route(handlers.SessionLifetime{Idle: time.Hour, Absolute: time.Nanosecond})
+				time.Sleep(time.Millisecond)
+			})
+			It("should send me to log in again", func() {
+				serve("GET", "/cell/"+cellId+"/edit", nil)
+				Expect(rr.Code).To(Equal(http.StatusFound))
+				Expect(rr.Header().Get("Location")).To(HavePrefix("/login"))
+			})
+		})
+		Context("given my session is still fresh", func() {
+			BeforeEach(func() {
+				route(handlers.SessionLifetime{Idle: time.Hour, Absolute: time.Hour})
+			})
+			It("should keep me logged in", func() {
+				serve("GET", "/cell/"+cellId+"/edit", nil)
+				Expect(rr.Code).To(Equal(http.StatusOK))
+			})
+			It("should log me out when I ask", func() {
+				token, _ := formToken(router, cookies)
+				serve("POST", "/logout", url.Values{"csrf_token": {token}})
+				Expect(rr.Code).To(Equal(http.StatusFound))
+				cookies = rr.Result().Cookies()
+				serve("GET", "/cell/"+cellId+"/edit", nil)
+				Expect(rr.Code).To(Equal(http.StatusFound))
+				Expect(rr.Header().Get("Location")).To(HavePrefix("/login"))
+			})
+		})
+	})
+
+	Describe("Throttling logins", func() {
+		loginWith := func(username string, password string) {
+			token, cookies := formToken(router, nil)
+			form := url.Values{"username": {username}, "password": {password}, "csrf_token": {token}}
+			req, err = http.NewRequest("POST", "http://localhost:8080/login", strings.NewReader(form.Encode()))
+			Expect(err).To(BeNil())
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			rr = httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+		}
+		login := func(password string) {
+			loginWith("funes", password)
+		}
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			handlers.RegisterRoutes(router, handlers.Labyrinth{
+				Entry: cellId,
+				Lob:   lobRepository,
+				Store: sessions.NewCookieStore([]byte("a test key")),
+				Limits: handlers.Limits{
+					LoginsByAccount: &ratelimit.Limiter{Free: 2, Base: time.Minute, Max: time.Minute},
+				},
+			})
+		})
+		It("should lock the account out after too many wrong passwords", func() {
+			login("minotaur")
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			login("asterion")
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			login("theseus")
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			login("labyrinth")
+			Expect(rr.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(rr.Header().Get("Retry-After")).To(Equal("60"))
+		})
+		It("should lock the account out however its name is written", func() {
+			loginWith("Funes", "minotaur")
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			loginWith("FUNES ", "asterion")
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			loginWith(" funes", "theseus")
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			login("labyrinth")
+			Expect(rr.Code).To(Equal(http.StatusTooManyRequests))
+		})
+		It("should let other accounts log in", func() {
+			Expect(loginAs(router, "borges")).NotTo(BeEmpty())
+		})
+	})
+
+	Describe("Keeping an audit log", func() {
+		serve := func(method string, path string, form url.Values, cookies []*http.Cookie) {
+			req, err = http.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(form.Encode()))
+			Expect(err).To(BeNil())
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			rr = httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+		}
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			handlers.RegisterRoutes(router, handlers.Labyrinth{
+				Entry: cellId,
+				Lob:   lobRepository,
+				Store: sessions.NewCookieStore([]byte("a test key")),
+			})
+			cookies := loginAs(router, "borges")
+			token, _ := formToken(router, cookies)
+			serve("POST", "/cell/"+cellId+"/addTag", url.Values{"tag": {"Audited"}, "csrf_token": {token}}, cookies)
+			Expect(rr.Code).To(Equal(http.StatusFound))
+		})
+		It("should show admins who changed the cell", func() {
+			serve("GET", "/admin/audit?cell="+cellId, nil, loginAs(router, "ireneo"))
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(ContainSubstring("borges"))
+			Expect(rr.Body.String()).To(ContainSubstring("add_tag"))
+		})
+		It("should export the changes as JSON lines", func() {
+			serve("GET", "/admin/audit.jsonl?user=borges", nil, loginAs(router, "ireneo"))
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+			var last struct {
+				Username string
+				Action   string
+				After    map[string]string
+			}
+			Expect(json.Unmarshal([]byte(lines[len(lines)-1]), &last)).To(Succeed())
+			Expect(last.Username).To(Equal("borges"))
+			Expect(last.Action).To(Equal("add_tag"))
+			Expect(last.After["tag"]).To(Equal("Audited"))
+		})
+		It("should keep it from editors", func() {
+			serve("GET", "/admin/audit", nil, loginAs(router, "borges"))
+			Expect(rr.Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("Using the API", func() {
+		var token string
+		serve := func(method string, path string, body string) {
+			req, err = http.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(body))
+			Expect(err).To(BeNil())
+			req.Header.Set("Content-Type", "application/json")
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rr = httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+		}
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			handlers.RegisterRoutes(router, handlers.Labyrinth{
+				Entry:  cellId,
+				Lob:    lobRepository,
+				Public: lobRepository.Public(),
+				Store:  sessions.NewCookieStore([]byte("a test key")),
+			})
+			token = ""
+		})
+		It("should let anyone read cells", func() {
+			serve("GET", "/api/v1/cells/"+cellId, "")
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(ContainSubstring(`"title":"Idea one"`))
+		})
+		It("should answer 401 in JSON to visitors who write", func() {
+			serve("POST", "/api/v1/cells", `{"title": "Anonymous", "body": "Anonymous", "room": "This is a room"}`)
+			Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+			Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+		})
+		It("should let editors create and delete cells with a token", func() {
+			secret, hash, err := models.GenerateToken()
+			Expect(err).To(BeNil())
+			_, err = lobRepository.NewAPIToken(models.APIToken{UserId: "0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21",
+				Name: "mobile", Scopes: []models.Scope{models.ScopeRead, models.ScopeWrite}}, hash)
+			Expect(err).To(BeNil())
+			token = secret
+			serve("POST", "/api/v1/cells", `{"title": "From the API", "body": "Created remotely", "room": "This is a room"}`)
+			Expect(rr.Code).To(Equal(http.StatusCreated))
+			location := rr.Header().Get("Location")
+			Expect(location).To(HavePrefix("/api/v1/cells/"))
+			serve("DELETE", location, "")
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+		})
+	})
+
+	Describe("Serving a labyrinth under a prefix", func() {
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			handlers.RegisterRoutes(router.PathPrefix("/team").Subrouter(), handlers.Labyrinth{
+				Name:   "team",
+				Prefix: "/team",
+				Entry:  cellId,
+				Lob:    lobRepository,
+			})
+		})
+		Context("given I enter the labyrinth", func() {
+			BeforeEach(func() {
+				req, err = http.NewRequest("GET", "http://localhost:8080/team/", nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should take me to its entry cell", func() {
+				Expect(rr.Code).To(Equal(http.StatusFound))
+				Expect(rr.Header().Get("Location")).To(Equal("/team/cell/" + cellId))
+			})
+		})
+		Context("given I view a cell", func() {
+			BeforeEach(func() {
+				req, err = http.NewRequest("GET", "http://localhost:8080/team/cell/"+cellId, nil)
+				Expect(err).To(BeNil())
+				router.ServeHTTP(rr, req)
+			})
+			It("should keep the links within the labyrinth", func() {
+				Expect(rr.Code).To(Equal(http.StatusOK))
+				Expect(rr.Body.String()).To(ContainSubstring(`href="/team/cell/`))
+				Expect(rr.Body.String()).NotTo(ContainSubstring(`href="/cell/`))
+			})
+		})
+	})
+	Describe("Negotiating the content of pages", func() {
+		serve := func(path string, accept string) {
+			req, err = http.NewRequest("GET", "http://localhost:8080"+path, nil)
+			Expect(err).To(BeNil())
+			req.Header.Set("Accept", accept)
+			router = mux.NewRouter()
+			router.HandleFunc("/cell/{id}", handlers.ViewHandler(lobRepository))
+			router.HandleFunc("/room/{room}", handlers.RoomHandler(lobRepository))
+			router.HandleFunc("/rooms", handlers.RoomListHandler(lobRepository))
+			router.ServeHTTP(rr, req)
+		}
+		It("should answer a cell in JSON", func() {
+			serve("/cell/"+cellId, "application/json")
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(rr.Header().Get("Vary")).To(Equal("Accept"))
+			var cell api.Cell
+			Expect(json.Unmarshal(rr.Body.Bytes(), &cell)).To(Succeed())
+			Expect(cell.Title).To(Equal("Idea one"))
+			Expect(len(cell.Links)).To(Equal(2))
+		})
+		It("should answer a cell in Markdown", func() {
+			serve("/cell/"+cellId, "text/markdown")
+			Expect(rr.Header().Get("Content-Type")).To(HavePrefix("text/markdown"))
+			Expect(rr.Body.String()).To(HavePrefix("# Idea one\n\n"))
+		})
+		It("should answer 404 in JSON for cells that do not exist", func() {
+			serve("/cell/thiscelldoesnotexist", "application/json")
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+			Expect(rr.Body.String()).To(ContainSubstring(`"error"`))
+		})
+		It("should answer rooms and their cells", func() {
+			serve("/rooms", "application/json")
+			var rooms []api.Room
+			Expect(json.Unmarshal(rr.Body.Bytes(), &rooms)).To(Succeed())
+			Expect(rooms).NotTo(BeEmpty())
+			rr = httptest.NewRecorder()
+			serve("/room/"+url.PathEscape("This is a room"), "text/markdown")
+			Expect(rr.Body.String()).To(ContainSubstring("- [Idea one](/cell/" + cellId + ")"))
+		})
+		It("should keep answering browsers in HTML", func() {
+			serve("/cell/"+cellId, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+			Expect(rr.Body.String()).To(ContainSubstring(`<ul class="card-link-list">`))
+		})
+	})
+
 })
 
 //extracts the substring from s contained within start and finish 
@@ -358,4 +1331,60 @@ func extractFromPage(s string, start string, end string) (string, error) {
 	}
 	//extract the links substring
 	return s[startIndex:endIndex], nil
-}
\ No newline at end of file
+}
+
+//logs in with the password of the test users and returns the session cookies
+func loginAs(router *mux.Router, username string) []*http.Cookie {
+	token, cookies := formToken(router, nil)
+	form := url.Values{"username": {username}, "password": {"labyrinth"}, "csrf_token": {token}}
+	req, err := http.NewRequest("POST", "http://localhost:8080/login", strings.NewReader(form.Encode()))
+	Expect(err).To(BeNil())
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	Expect(rr.Code).To(Equal(http.StatusFound))
+	return rr.Result().Cookies()
+}
+
+//opens the login form and returns the token its forms carry along with the session cookies
+func formToken(router *mux.Router, cookies []*http.Cookie) (string, []*http.Cookie) {
+	req, err := http.NewRequest("GET", "http://localhost:8080/login", nil)
+	Expect(err).To(BeNil())
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	Expect(rr.Code).To(Equal(http.StatusOK))
+	token := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindStringSubmatch(rr.Body.String())
+	Expect(token).To(HaveLen(2))
+	if set := rr.Result().Cookies(); len(set) > 0 {
+		cookies = set
+	}
+	return token[1], cookies
+}
+
+//fills the variables of the path of a route with values of the test labyrinth
+func examplePath(route handlers.Route, cellId string) string {
+	values := map[string]string{
+		"id":     cellId,
+		"title":  "Idea two",
+		"page":   "new_card.html",
+		"room":   "This is a room",
+		"tag":    "Ethics",
+		"format": "dot",
+		"other":  "417ecfe7-d2b4-4e43-afd4-dbf5f431d97d",
+		"source": "Analects",
+	}
+	path := regexp.MustCompile(`\{(\w+)(:[^}]*)?\}`).ReplaceAllStringFunc(route.Path, func(variable string) string {
+		name := regexp.MustCompile(`\w+`).FindString(variable)
+		return url.PathEscape(values[name])
+	})
+	if route.Prefix {
+		path += "graph.js"
+	}
+	return path
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"text/template"
+
+	"github.com/dacero/labyrinth-of-babel/graph"
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/dacero/labyrinth-of-babel/similarity"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+//one of the labyrinths served, with everything its handlers need
+type Labyrinth struct {
+	Name string
+	//the labyrinth is served at this hostname when set
+	Host string
+	//or under this path, "" for the root of the server
+	Prefix string
+	//id of the cell visitors land on
+	Entry     string
+	Lob       repository.LobRepository
+	Store     *sessions.CookieStore
+	Lifetime  SessionLifetime
+	Limits    Limits
+	Analyzer  *graph.Analyzer
+	Suggester *similarity.Engine
+	//what visitors who have not logged in see, Lob and Analyzer when nil
+	Public         repository.LobRepository
+	PublicAnalyzer *graph.Analyzer
+}
+
+//returns the labyrinth as seen by visitors who have not logged in
+func (l Labyrinth) forVisitors() Labyrinth {
+	if l.Public != nil {
+		l.Lob = l.Public
+	}
+	if l.PublicAnalyzer != nil {
+		l.Analyzer = l.PublicAnalyzer
+	}
+	return l
+}
+
+//returns the labyrinth as seen by a user who has logged in, who makes its changes
+func (l Labyrinth) forUser(user models.User) Labyrinth {
+	if l.Lob != nil {
+		l.Lob = l.Lob.WithActor(user)
+	}
+	return l
+}
+
+type contextKey int
+
+//what handlers find in the context of a request
+const (
+	labyrinthKey contextKey = iota
+	userKey
+	csrfKey
+)
+
+//InLabyrinth makes the labyrinth available to the handlers serving the request
+func InLabyrinth(labyrinth Labyrinth) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), labyrinthKey, labyrinth)))
+		})
+	}
+}
+
+//returns the labyrinth serving the request, the default one if none was set
+func currentLabyrinth(r *http.Request) Labyrinth {
+	if labyrinth, ok := r.Context().Value(labyrinthKey).(Labyrinth); ok {
+		return labyrinth
+	}
+	return Labyrinth{Entry: "entry"}
+}
+
+//returns the path all the pages of the labyrinth serving the request are under
+func basePath(r *http.Request) string {
+	return currentLabyrinth(r).Prefix
+}
+
+//each labyrinth keeps its own session so logging into one does not open the others
+func sessionName(r *http.Request) string {
+	if name := currentLabyrinth(r).Name; name != "" {
+		return "lob-session-" + name
+	}
+	return "lob-session"
+}
+
+//parses a template of the templates folder, templates use {{base}} in
+//their <base href> so relative links stay within the labyrinth
+func parseTemplate(r *http.Request, name string) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"base": func() string { return basePath(r) },
+		"role": func() models.Role { return currentRole(r) },
+		"csrf": func() string { return csrfToken(r) },
+		//a segment of a path, such as a tag, escaped to be put in a link
+		"pathEscape": url.PathEscape,
+	}
+	return template.New(filepath.Base(name)).Funcs(funcs).ParseFiles("./templates/" + name)
+}
+
+//redirects to a path within the labyrinth serving the request
+func redirect(w http.ResponseWriter, r *http.Request, path string, code int) {
+	http.Redirect(w, r, basePath(r)+path, code)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+)
+
+const (
+	htmlType     = "text/html"
+	jsonType     = "application/json"
+	markdownType = "text/markdown"
+)
+
+//returns the offered media type the Accept header of the request prefers,
+//naming it rather than matching */* when qualities tie, and the first one
+//offered when it does not prefer any
+func negotiate(r *http.Request, offers ...string) string {
+	best, bestQuality, bestSpecificity := offers[0], 0.0, -1
+	for _, offer := range offers {
+		quality, specificity := acceptQuality(r.Header.Get("Accept"), offer)
+		if quality > bestQuality || quality == bestQuality && quality > 0 && specificity > bestSpecificity {
+			best, bestQuality, bestSpecificity = offer, quality, specificity
+		}
+	}
+	return best
+}
+
+//the quality the Accept header gives to the media type, from its most
+//specific range that matches it, and how specific that range is
+func acceptQuality(accept string, mediaType string) (float64, int) {
+	quality, specificity := 0.0, -1
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		s := -1
+		switch {
+		case name == mediaType:
+			s = 2
+		case name == strings.SplitN(mediaType, "/", 2)[0]+"/*":
+			s = 1
+		case name == "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				if parsed, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		quality, specificity = q, s
+	}
+	return quality, specificity
+}
+
+//answers with the JSON or the Markdown of a page when the request prefers
+//them to HTML, returning whether it did; err is the error of reading what
+//the page shows, answered as a 404
+func represent(w http.ResponseWriter, r *http.Request, err error, value func() interface{}, markdown func() string) bool {
+	w.Header().Add("Vary", "Accept")
+	switch negotiate(r, htmlType, jsonType, markdownType) {
+	case jsonType:
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Not found"})
+			return true
+		}
+		if err := json.NewEncoder(w).Encode(value()); err != nil {
+			log.Printf("Error when writing JSON: %s", err)
+		}
+		return true
+	case markdownType:
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "Not found\n")
+			return true
+		}
+		fmt.Fprint(w, markdown())
+		return true
+	}
+	return false
+}
+
+//the Markdown of a cell, its body under its title
+func cellMarkdown(cell models.Cell) string {
+	if cell.Title == "" {
+		return cell.Body + "\n"
+	}
+	return "# " + cell.Title + "\n\n" + cell.Body + "\n"
+}
+
+//the Markdown of a list of cells, linking each of them
+func cellsMarkdown(r *http.Request, title string, cells []models.Cell) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	for _, cell := range cells {
+		name := cell.Title
+		if name == "" {
+			name = cell.Id
+		}
+		fmt.Fprintf(&b, "- [%s](%s/cell/%s)\n", name, basePath(r), cell.Id)
+	}
+	return b.String()
+}
+
+//the Markdown of the rooms, linking each of them
+func roomsMarkdown(r *http.Request, rooms []models.CollectionOfCells) string {
+	var b strings.Builder
+	fmt.Fprint(&b, "# Rooms\n\n")
+	for _, room := range rooms {
+		fmt.Fprintf(&b, "- [%s](%s/room/%s) (%d cells)\n", room.Name, basePath(r), url.PathEscape(room.Name), room.CellCount)
+	}
+	return b.String()
+}
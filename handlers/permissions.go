@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/gorilla/mux"
+)
+
+//identify finds the user of the API token or logged in the session, if any,
+//and makes it available to the handlers serving the request; sessions idle
+//or open for longer than the lifetime of the labyrinth are ended
+func identify(l Labyrinth) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if bearer, ok := bearerToken(r); ok {
+				user, err := tokenUser(l, bearer)
+				if err != nil {
+					deny(w, r, http.StatusUnauthorized, "Invalid API token")
+					return
+				}
+				next.ServeHTTP(w, withUser(l, r, user))
+				return
+			}
+			//store == nil is for testing purposes
+			if l.Store == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			session, err := l.Store.Get(r, sessionName(r))
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			userId, _ := session.Values["user_id"].(string)
+			if userId == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			now := time.Now()
+			if l.Lifetime.expired(session, now) {
+				endSession(session)
+				if err := session.Save(r, w); err != nil {
+					log.Printf("Error when ending an expired session: %s", err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			user, err := l.Lob.GetUser(userId)
+			if err != nil {
+				//the user is gone, so is its session
+				next.ServeHTTP(w, r)
+				return
+			}
+			if touchSession(session, now) {
+				if err := session.Save(r, w); err != nil {
+					log.Printf("Error when saving the session: %s", err)
+				}
+			}
+			next.ServeHTTP(w, withUser(l, r, user))
+		})
+	}
+}
+
+//makes the user, and the repository of the labyrinth as the user sees it,
+//which records its changes as the user's, available to the handlers
+func withUser(l Labyrinth, r *http.Request, user models.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userKey, user)
+	return r.WithContext(repository.NewContext(ctx, l.forUser(user).Lob))
+}
+
+//returns the token in the Authorization header, if any
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[len("Bearer "):]), true
+}
+
+//returns the user of the token, with the role its scopes let it use
+func tokenUser(l Labyrinth, bearer string) (models.User, error) {
+	token, err := l.Lob.UseAPIToken(models.HashToken(bearer))
+	if err != nil {
+		return models.User{}, err
+	}
+	user, err := l.Lob.GetUser(token.UserId)
+	if err != nil {
+		return user, err
+	}
+	user.Role = token.Cap(user.Role)
+	return user, nil
+}
+
+//sessionOnly lets only users logged in with a session use the handler, not
+//API clients, so that a token cannot make or revoke tokens, nor make one with
+//scopes it does not have
+func sessionOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := bearerToken(r); ok {
+			deny(w, r, http.StatusForbidden, "API tokens can only be managed after logging in")
+			return
+		}
+		next(w, r)
+	}
+}
+
+//returns the user logged in, if any
+func currentUser(r *http.Request) (models.User, bool) {
+	user, ok := r.Context().Value(userKey).(models.User)
+	return user, ok
+}
+
+//returns the role of whoever is making the request
+func currentRole(r *http.Request) models.Role {
+	if user, ok := currentUser(r); ok {
+		return user.Role
+	}
+	return models.RoleAnyone
+}
+
+//allow only lets users with the role, or a higher one, use the handler;
+//browsers of visitors are sent to log in, API clients get a 401, and users
+//without the role a 403
+func allow(role models.Role, next http.Handler) http.Handler {
+	if role == models.RoleAnyone {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentRole(r).Allows(role) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := currentUser(r); ok {
+			deny(w, r, http.StatusForbidden, "You are not allowed to do this")
+			return
+		}
+		if isAPIClient(r) {
+			deny(w, r, http.StatusUnauthorized, "You need to log in to do this")
+			return
+		}
+		login := "/login"
+		if r.Method == http.MethodGet {
+			back := strings.TrimPrefix(r.URL.RequestURI(), basePath(r))
+			login += "?redirect=" + url.QueryEscape(back)
+		}
+		redirect(w, r, login, http.StatusFound)
+	})
+}
+
+//API clients get a status code they can act on instead of a login page
+func isAPIClient(r *http.Request) bool {
+	if _, ok := bearerToken(r); ok {
+		return true
+	}
+	if strings.HasPrefix(strings.TrimPrefix(r.URL.Path, basePath(r)), "/api/") {
+		return true
+	}
+	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+//answers with the status, in JSON for API clients
+func deny(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if !isAPIClient(r) {
+		http.Error(w, message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
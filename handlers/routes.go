@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dacero/labyrinth-of-babel/api"
+	"github.com/dacero/labyrinth-of-babel/gql"
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/gorilla/mux"
+)
+
+//a page or action of the labyrinth and who is allowed to use it
+type Route struct {
+	Path string
+	//matches every path under Path too
+	Prefix  bool
+	Methods []string
+	//the lowest role allowed to use the route
+	Role    models.Role
+	Handler http.HandlerFunc
+}
+
+var (
+	get  = []string{"GET"}
+	post = []string{"POST"}
+)
+
+//Routes declares every route of the labyrinth with the role it needs, the
+//endpoints of the API with the role they declare
+func Routes(l Labyrinth) []Route {
+	lob, store := l.Lob, l.Store
+	routes := []Route{
+		{Path: "/", Methods: get, Role: models.RoleAnyone, Handler: EntryHandler()},
+		{Path: "/cell/{id}", Methods: get, Role: models.RoleAnyone, Handler: ViewHandler(lob)},
+		{Path: "/cell/{id}/edit", Methods: get, Role: models.RoleEditor, Handler: EditHandler(lob)},
+		{Path: "/cell/{id}/sources", Methods: get, Role: models.RoleEditor, Handler: SourcesHandler(lob)},
+		{Path: "/cell/{id}/addSource", Methods: post, Role: models.RoleEditor, Handler: AddSourceHandler(lob)},
+		{Path: "/cell/{id}/removeSource", Methods: post, Role: models.RoleEditor, Handler: RemoveSourceHandler(lob)},
+		{Path: "/cell/{id}/addTag", Methods: post, Role: models.RoleEditor, Handler: AddTagHandler(lob)},
+		{Path: "/cell/{id}/removeTag", Methods: post, Role: models.RoleEditor, Handler: RemoveTagHandler(lob)},
+		{Path: "/cell/{id}/links", Methods: get, Role: models.RoleEditor, Handler: LinksHandler(lob, l.Suggester)},
+		{Path: "/cell/{id}/linkCell", Methods: post, Role: models.RoleEditor, Handler: LinkCellsHandler(lob)},
+		{Path: "/cell/{id}/unlinkCell", Methods: post, Role: models.RoleEditor, Handler: UnlinkCellsHandler(lob)},
+		{Path: "/cell/{id}/neighbourhood", Methods: get, Role: models.RoleAnyone, Handler: NeighbourhoodHandler(lob)},
+		{Path: "/cell/{id}/neighbourhood.json", Methods: get, Role: models.RoleAnyone, Handler: NeighbourhoodJSONHandler(lob)},
+		{Path: "/cell/{id}/graph", Methods: get, Role: models.RoleAnyone, Handler: GraphHandler()},
+		{Path: "/wiki/{title:.+}", Methods: get, Role: models.RoleAnyone, Handler: WikiHandler(lob)},
+		{Path: "/save", Methods: post, Role: models.RoleEditor, Handler: SaveHandler(lob)},
+		{Path: "/new", Methods: post, Role: models.RoleEditor, Handler: CreateHandler(lob)},
+		{Path: "/searchSources", Methods: get, Role: models.RoleAnyone, Handler: throttleExpensive(l.Limits, SearchSourcesHandler(lob))},
+		{Path: "/searchRooms", Methods: get, Role: models.RoleAnyone, Handler: throttleExpensive(l.Limits, SearchRoomsHandler(lob))},
+		{Path: "/searchTags", Methods: get, Role: models.RoleAnyone, Handler: throttleExpensive(l.Limits, SearchTagsHandler(lob))},
+		{Path: "/searchCells", Methods: get, Role: models.RoleAnyone, Handler: throttleExpensive(l.Limits, SearchCellsHandler(lob))},
+		{Path: "/page/{page}", Methods: get, Role: models.RoleAnyone, Handler: PageHandler()},
+		{Path: "/rooms", Methods: get, Role: models.RoleAnyone, Handler: RoomListHandler(lob)},
+		{Path: "/room/{room}", Methods: get, Role: models.RoleAnyone, Handler: RoomHandler(lob)},
+		{Path: "/room/{room}/visibility", Methods: post, Role: models.RoleAdmin, Handler: RoomVisibilityHandler(lob)},
+		{Path: "/tag/{tag:.+}", Methods: get, Role: models.RoleAnyone, Handler: TagHandler(lob)},
+		{Path: "/reports/orphans", Methods: get, Role: models.RoleEditor, Handler: OrphansHandler(lob)},
+		{Path: "/insights", Methods: get, Role: models.RoleAnyone, Handler: InsightsHandler(l.Analyzer)},
+		{Path: "/export/{format}", Methods: get, Role: models.RoleAnyone, Handler: throttleExpensive(l.Limits, ExportHandler(l.Analyzer))},
+		{Path: "/vault.zip", Methods: get, Role: models.RoleReader, Handler: throttleExpensive(l.Limits, VaultHandler(lob))},
+		{Path: "/graph", Methods: get, Role: models.RoleAnyone, Handler: GraphHandler()},
+		{Path: "/graph.json", Methods: get, Role: models.RoleAnyone, Handler: GraphJSONHandler(l.Analyzer)},
+		{Path: "/static/", Prefix: true, Methods: get, Role: models.RoleAnyone, Handler: http.StripPrefix(l.Prefix+"/static/", http.FileServer(http.Dir("./static"))).ServeHTTP},
+		{Path: "/login", Methods: get, Role: models.RoleAnyone, Handler: LoginHandler(lob, store)},
+		{Path: "/login", Methods: post, Role: models.RoleAnyone, Handler: throttleLogins(l.Limits, lob, LoginHandler(lob, store))},
+		{Path: "/logout", Methods: post, Role: models.RoleAnyone, Handler: LogoutHandler(store)},
+		{Path: "/account/tokens", Methods: get, Role: models.RoleReader, Handler: sessionOnly(TokensHandler(lob))},
+		{Path: "/account/tokens", Methods: post, Role: models.RoleReader, Handler: sessionOnly(NewTokenHandler(lob))},
+		{Path: "/account/tokens/{id}/revoke", Methods: post, Role: models.RoleReader, Handler: sessionOnly(RevokeTokenHandler(lob))},
+		{Path: "/admin/users", Methods: get, Role: models.RoleAdmin, Handler: UsersHandler(lob)},
+		{Path: "/admin/users", Methods: post, Role: models.RoleAdmin, Handler: NewUserHandler(lob)},
+		{Path: "/admin/users/{id}/role", Methods: post, Role: models.RoleAdmin, Handler: SetRoleHandler(lob)},
+		{Path: "/admin/audit", Methods: get, Role: models.RoleAdmin, Handler: AuditHandler(lob)},
+		{Path: "/admin/audit.jsonl", Methods: get, Role: models.RoleAdmin, Handler: AuditExportHandler(lob)},
+		{Path: "/graphql", Methods: []string{"GET", "POST"}, Role: models.RoleAnyone, Handler: throttleExpensive(l.Limits, gql.Handler(lob))},
+		{Path: "/api/openapi.json", Methods: get, Role: models.RoleAnyone, Handler: api.DocumentHandler("./api/openapi.json")},
+	}
+	for _, endpoint := range api.Endpoints(lob) {
+		routes = append(routes, Route{Path: api.Prefix + endpoint.Path, Methods: []string{endpoint.Method}, Role: endpoint.Role, Handler: endpoint.Handler})
+	}
+	return routes
+}
+
+//RegisterRoutes serves the labyrinth from the router, which is already
+//restricted to its host and prefix
+func RegisterRoutes(r *mux.Router, l Labyrinth) {
+	r.Use(InLabyrinth(l), identify(l), csrfTokens(l))
+	routes, visitorRoutes := Routes(l), Routes(l.forVisitors())
+	if len(routes) != len(visitorRoutes) {
+		panic("Visitors and users have different routes")
+	}
+	for i, route := range routes {
+		visitor := visitorRoutes[i]
+		if visitor.Path != route.Path || strings.Join(visitor.Methods, " ") != strings.Join(route.Methods, " ") {
+			panic("The routes of visitors differ from the routes of users at " + route.Path)
+		}
+		route.Handler = byVisitor(route.Handler, visitor.Handler)
+		var match *mux.Route
+		if route.Prefix {
+			match = r.PathPrefix(route.Path)
+		} else {
+			match = r.Path(route.Path)
+		}
+		if len(route.Methods) > 0 {
+			match = match.Methods(route.Methods...)
+		}
+		match.Handler(allow(route.Role, checkCSRF(route.Handler)))
+	}
+}
+
+//serves visitors with the handler of the public labyrinth, and users who
+//have logged in with the handler of the whole labyrinth, whose handlers
+//find the repository recording the changes as theirs in the request
+func byVisitor(user http.HandlerFunc, visitor http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := currentUser(r); ok {
+			user(w, r)
+			return
+		}
+		visitor(w, r)
+	}
+}
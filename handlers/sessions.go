@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+//how long a session of a user lasts, no limit when zero
+type SessionLifetime struct {
+	//since the last request of the session
+	Idle time.Duration
+	//since the user logged in
+	Absolute time.Duration
+}
+
+//the session is only written again when it was last seen this long ago,
+//so not every request rewrites the cookie
+const seenResolution = time.Minute
+
+//remembers the user logged in the session and when
+func startSession(session *sessions.Session, userId string, now time.Time) {
+	session.Values["user_id"] = userId
+	session.Values["login_time"] = now.Unix()
+	session.Values["seen_time"] = now.Unix()
+}
+
+//forgets the user logged in the session
+func endSession(session *sessions.Session) {
+	delete(session.Values, "user_id")
+	delete(session.Values, "login_time")
+	delete(session.Values, "seen_time")
+}
+
+//whether the session has been idle or open for longer than allowed
+func (lifetime SessionLifetime) expired(session *sessions.Session, now time.Time) bool {
+	login, _ := session.Values["login_time"].(int64)
+	seen, _ := session.Values["seen_time"].(int64)
+	if lifetime.Absolute > 0 && now.Sub(time.Unix(login, 0)) > lifetime.Absolute {
+		return true
+	}
+	return lifetime.Idle > 0 && now.Sub(time.Unix(seen, 0)) > lifetime.Idle
+}
+
+//records a request of the session, returns whether the session changed
+func touchSession(session *sessions.Session, now time.Time) bool {
+	seen, _ := session.Values["seen_time"].(int64)
+	if now.Sub(time.Unix(seen, 0)) < seenResolution {
+		return false
+	}
+	session.Values["seen_time"] = now.Unix()
+	return true
+}
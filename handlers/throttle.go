@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dacero/labyrinth-of-babel/ratelimit"
+	"github.com/dacero/labyrinth-of-babel/repository"
+)
+
+//the limiters throttling the labyrinth, nothing is throttled by a nil one
+type Limits struct {
+	//failed logins from an address
+	LoginsByAddress *ratelimit.Limiter
+	//failed logins to an account, wherever they come from
+	LoginsByAccount *ratelimit.Limiter
+	//searches and other expensive requests from an address
+	Expensive *ratelimit.Limiter
+}
+
+//DefaultLimits lock an account out for a quarter of an hour after a dozen
+//failed logins, and let an address search about once a second
+func DefaultLimits() Limits {
+	return Limits{
+		LoginsByAddress: &ratelimit.Limiter{Free: 10, Base: time.Second, Max: 15 * time.Minute, Decay: 5 * time.Minute},
+		LoginsByAccount: &ratelimit.Limiter{Free: 5, Base: time.Second, Max: 15 * time.Minute, Decay: 15 * time.Minute},
+		Expensive:       &ratelimit.Limiter{Free: 30, Base: time.Second, Max: time.Minute, Decay: time.Second},
+	}
+}
+
+//tells login attempts apart by the account they try; the database finds
+//a user whatever the case or accents of the name, so the id of the user it
+//finds is the key, and the name in lower case when it finds none
+func byAccount(lob repository.LobRepository) ratelimit.Key {
+	return func(r *http.Request) string {
+		username := strings.TrimSpace(r.FormValue("username"))
+		if username == "" {
+			return ""
+		}
+		if user, err := lob.GetUserByUsername(username); err == nil {
+			return "user " + user.Id
+		}
+		return "name " + strings.ToLower(username)
+	}
+}
+
+//throttleLogins makes addresses and accounts that keep failing to log in wait
+func throttleLogins(limits Limits, lob repository.LobRepository, next http.HandlerFunc) http.HandlerFunc {
+	failed := func(status int) bool { return status == http.StatusUnauthorized }
+	handler := http.Handler(next)
+	if limits.LoginsByAccount != nil {
+		handler = limits.LoginsByAccount.Throttle(byAccount(lob), failed, tooManyRequests)(handler)
+	}
+	if limits.LoginsByAddress != nil {
+		handler = limits.LoginsByAddress.Throttle(ratelimit.ByAddress, failed, tooManyRequests)(handler)
+	}
+	return handler.ServeHTTP
+}
+
+//throttleExpensive makes addresses that keep asking for an expensive page wait
+func throttleExpensive(limits Limits, next http.HandlerFunc) http.HandlerFunc {
+	if limits.Expensive == nil {
+		return next
+	}
+	return limits.Expensive.Throttle(ratelimit.ByAddress, nil, tooManyRequests)(next).ServeHTTP
+}
+
+//tells whoever is throttled how long to wait
+func tooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	log.Printf("Throttled %s %s from %s for %ds", r.Method, r.URL.Path, ratelimit.ByAddress(r), seconds)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	deny(w, r, http.StatusTooManyRequests, fmt.Sprintf("Too many attempts, try again in %d seconds", seconds))
+}
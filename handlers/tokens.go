@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/gorilla/mux"
+)
+
+//shows the API tokens of the user, and the one just created if any
+func showTokens(w http.ResponseWriter, r *http.Request, lob repository.LobRepository, created string) {
+	user, _ := currentUser(r)
+	tokens, err := lob.ListAPITokens(user.Id)
+	if err != nil {
+		log.Printf("Error when listing API tokens: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	t, err := parseTemplate(r, "tokens.gohtml")
+	if err != nil {
+		log.Printf("Error when parsing the tokens template: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	type data struct {
+		Tokens  []models.APIToken
+		Scopes  []models.Scope
+		Created string
+	}
+	err = t.Execute(w, data{Tokens: tokens, Scopes: models.Scopes, Created: created})
+	if err != nil {
+		log.Printf("Error when returning API tokens: %s", err)
+	}
+}
+
+//TokensHandler lists the API tokens of the user
+func TokensHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		showTokens(w, r, lob, "")
+	})
+}
+
+//NewTokenHandler creates an API token for the user and shows it, only this once
+func NewTokenHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		user, _ := currentUser(r)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		token := models.APIToken{UserId: user.Id, Name: r.PostFormValue("name")}
+		for _, name := range r.PostForm["scope"] {
+			scope, err := models.ParseScope(name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			token.Scopes = append(token.Scopes, scope)
+		}
+		secret, hash, err := models.GenerateToken()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, err = lob.NewAPIToken(token, hash)
+		if err != nil {
+			log.Printf("Error when creating API token: %s", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		showTokens(w, r, lob, secret)
+	})
+}
+
+//RevokeTokenHandler revokes one of the API tokens of the user
+func RevokeTokenHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		user, _ := currentUser(r)
+		err := lob.RevokeAPIToken(user.Id, mux.Vars(r)["id"])
+		if err != nil {
+			log.Printf("Error when revoking API token: %s", err)
+			http.Error(w, "API token not found", http.StatusNotFound)
+			return
+		}
+		redirect(w, r, "/account/tokens", http.StatusFound)
+	})
+}
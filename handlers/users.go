@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/gorilla/mux"
+)
+
+//UsersHandler lists the users of the labyrinth so admins can manage them
+func UsersHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		users, err := lob.ListUsers()
+		if err != nil {
+			log.Printf("Error when listing users: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		t, err := parseTemplate(r, "users.gohtml")
+		if err != nil {
+			log.Printf("Error when parsing the users template: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		type data struct {
+			Users []models.User
+			Roles []models.Role
+		}
+		err = t.Execute(w, data{Users: users, Roles: models.Roles})
+		if err != nil {
+			log.Printf("Error when returning users: %s", err)
+		}
+	})
+}
+
+//NewUserHandler creates a user with the username, password and role posted
+func NewUserHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		role, err := models.ParseRole(r.PostFormValue("role"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		password := r.PostFormValue("password")
+		if len(password) < models.MinPasswordLength {
+			http.Error(w, "The password is too short", http.StatusBadRequest)
+			return
+		}
+		hash, err := models.HashPassword(password)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, err = lob.NewUser(models.User{Username: r.PostFormValue("username"), PasswordHash: hash, Role: role})
+		if err != nil {
+			log.Printf("Error when creating user: %s", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		redirect(w, r, "/admin/users", http.StatusFound)
+	})
+}
+
+//SetRoleHandler changes the role of a user, admins cannot change their own
+func SetRoleHandler(lob repository.LobRepository) func(w http.ResponseWriter, r *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lob := repository.FromContext(r.Context(), lob)
+		userId := mux.Vars(r)["id"]
+		if user, ok := currentUser(r); ok && user.Id == userId {
+			http.Error(w, "You cannot change your own role", http.StatusBadRequest)
+			return
+		}
+		role, err := models.ParseRole(r.PostFormValue("role"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = lob.SetUserRole(userId, role)
+		if err != nil {
+			log.Printf("Error when changing the role of %s: %s", userId, err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		redirect(w, r, "/admin/users", http.StatusFound)
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dacero/labyrinth-of-babel/graph"
+	"github.com/dacero/labyrinth-of-babel/handlers"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/dacero/labyrinth-of-babel/similarity"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+//how a labyrinth is declared in LABYRINTHS
+type labyrinthConfig struct {
+	Name     string `json:"name"`
+	Database string `json:"database"`
+	Host     string `json:"host"`
+	Prefix   string `json:"prefix"`
+	Entry    string `json:"entry"`
+}
+
+//reads the labyrinths to serve from LABYRINTHS, a JSON list, or serves
+//the one in MYSQL_DATABASE at the root when it is not set
+func loadLabyrinths() ([]labyrinthConfig, error) {
+	var configs []labyrinthConfig
+	value := os.Getenv("LABYRINTHS")
+	if value == "" {
+		return []labyrinthConfig{{Database: os.Getenv("MYSQL_DATABASE"), Entry: "entry"}}, nil
+	}
+	if err := json.Unmarshal([]byte(value), &configs); err != nil {
+		return nil, fmt.Errorf("LABYRINTHS is not a valid list of labyrinths: %s", err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("LABYRINTHS does not declare any labyrinth")
+	}
+	names := make(map[string]bool)
+	for i := range configs {
+		config := &configs[i]
+		if config.Name == "" || config.Database == "" {
+			return nil, fmt.Errorf("every labyrinth needs a name and a database")
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("labyrinth %s is declared twice", config.Name)
+		}
+		names[config.Name] = true
+		config.Prefix = strings.TrimRight(config.Prefix, "/")
+		if config.Prefix != "" && !strings.HasPrefix(config.Prefix, "/") {
+			config.Prefix = "/" + config.Prefix
+		}
+		if config.Entry == "" {
+			config.Entry = "entry"
+		}
+	}
+	return configs, nil
+}
+
+//returns the labyrinth with that name, the first one if name is empty
+func findLabyrinth(configs []labyrinthConfig, name string) (labyrinthConfig, error) {
+	if name == "" {
+		return configs[0], nil
+	}
+	for _, config := range configs {
+		if config.Name == name {
+			return config, nil
+		}
+	}
+	return labyrinthConfig{}, fmt.Errorf("there is no labyrinth named %s", name)
+}
+
+//opens the repository of the labyrinth, with its analysis and similarity index
+//cached until cells or links change; visitors who have not logged in get
+//their own analysis, without private cells
+func openLabyrinth(config labyrinthConfig, store *sessions.CookieStore, lifetime handlers.SessionLifetime) handlers.Labyrinth {
+	repo := repository.NewLobRepositoryFor(config.Database)
+	analyzer := graph.NewAnalyzer(repo)
+	publicAnalyzer := graph.NewAnalyzer(repo.Public())
+	suggester := similarity.NewEngine(repo)
+	lob := graph.Watch(repo, analyzer, publicAnalyzer, suggester)
+	return handlers.Labyrinth{
+		Name:           config.Name,
+		Host:           config.Host,
+		Prefix:         config.Prefix,
+		Entry:          config.Entry,
+		Lob:            lob,
+		Store:          store,
+		Lifetime:       lifetime,
+		Limits:         handlers.DefaultLimits(),
+		Analyzer:       analyzer,
+		Suggester:      suggester,
+		Public:         lob.Public(),
+		PublicAnalyzer: publicAnalyzer,
+	}
+}
+
+//serves every labyrinth from the router, the most specific ones first so a
+//labyrinth at the root does not hide the others
+func routeLabyrinths(r *mux.Router, labyrinths []handlers.Labyrinth) {
+	specificity := func(l handlers.Labyrinth) int {
+		s := len(l.Prefix)
+		if l.Host != "" {
+			s += 1 << 16
+		}
+		return s
+	}
+	sort.SliceStable(labyrinths, func(i, j int) bool {
+		return specificity(labyrinths[i]) > specificity(labyrinths[j])
+	})
+	for _, labyrinth := range labyrinths {
+		route := r.NewRoute()
+		if labyrinth.Host != "" {
+			route = route.Host(labyrinth.Host)
+		}
+		if labyrinth.Prefix != "" {
+			entry := r.NewRoute()
+			if labyrinth.Host != "" {
+				entry = entry.Host(labyrinth.Host)
+			}
+			entry.Path(labyrinth.Prefix).Handler(http.RedirectHandler(labyrinth.Prefix+"/", http.StatusMovedPermanently))
+			route = route.PathPrefix(labyrinth.Prefix)
+		}
+		handlers.RegisterRoutes(route.Subrouter(), labyrinth)
+	}
+}
@@ -1,12 +1,20 @@
 package models
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sync"
 	"time"
 	"strings"
 	"regexp"
+	"net/url"
 	
 	"github.com/russross/blackfriday/v2"
 	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Cell struct {
@@ -17,11 +25,68 @@ type Cell struct {
 	Create_time time.Time
 	Update_time time.Time
 	Sources     []Source
+	Tags        []Tag
 	Links       []Cell
+	//only users who have logged in can see private cells
+	Private bool
+}
+
+//a [[Cell Title]] or [[id|label]] link written in the body of a cell
+type WikiLink struct {
+	//the title or the id of the linked cell
+	Target string
+	Label  string
+	ById   bool
+}
+
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|]+)(?:\|([^\[\]]+))?\]\]`)
+
+func parseWikiLink(match []string) WikiLink {
+	target := strings.TrimSpace(match[1])
+	if match[2] != "" {
+		return WikiLink{Target: target, Label: strings.TrimSpace(match[2]), ById: true}
+	}
+	return WikiLink{Target: target, Label: target}
+}
+
+//returns the wiki links in the body of the cell, in order of appearance
+func (c Cell) WikiLinks() []WikiLink {
+	var links []WikiLink
+	for _, match := range wikiLinkPattern.FindAllStringSubmatch(c.Body, -1) {
+		links = append(links, parseWikiLink(match))
+	}
+	return links
+}
+
+//replaces every wiki link in the body with what replace returns for it
+func ReplaceWikiLinks(body string, replace func(WikiLink) string) string {
+	return wikiLinkPattern.ReplaceAllStringFunc(body, func(s string) string {
+		return replace(parseWikiLink(wikiLinkPattern.FindStringSubmatch(s)))
+	})
+}
+
+//turns wiki links into markdown links, titles are resolved by /wiki/{title}
+func renderWikiLinks(body string) string {
+	return ReplaceWikiLinks(body, func(link WikiLink) string {
+		label := strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`).Replace(link.Label)
+		if link.ById {
+			return "[" + label + "](/cell/" + url.PathEscape(link.Target) + ")"
+		}
+		return "[" + label + "](/wiki/" + url.PathEscape(link.Target) + ")"
+	})
+}
+
+//links within the labyrinth, starting with a single slash
+var localLinkPattern = regexp.MustCompile(`(href|src)="/([^/])`)
+
+//renders the body for a labyrinth served under base, so links within
+//the labyrinth stay within it
+func (c Cell) HTMLBodyAt(base string) string {
+	return localLinkPattern.ReplaceAllString(c.HTMLBody(), `$1="`+base+`/$2`)
 }
 
 func (c Cell) HTMLBody() string {
-	groomedString := strings.ReplaceAll(c.Body, "\r\n", "\n")
+	groomedString := renderWikiLinks(strings.ReplaceAll(c.Body, "\r\n", "\n"))
 	unsafe := blackfriday.Run([]byte(groomedString))
 	output := bluemonday.UGCPolicy().SanitizeBytes(unsafe)
 	return string(output)
@@ -55,8 +120,238 @@ func (s Source) String() string {
 	return string(s.Source)
 }
 
+type Tag struct {
+	Tag string
+}
+
+func (t Tag) String() string {
+	return string(t.Tag)
+}
+
 type CollectionOfCells struct {
 	Name		string
 	CellCount	int
 	Create_time time.Time
-}
\ No newline at end of file
+	Private     bool
+}
+
+//a link between two cells, it works in both directions
+type Link struct {
+	CellA string
+	CellB string
+}
+
+//a cell found while walking the labyrinth from another one
+type Neighbour struct {
+	Cell     Cell
+	Distance int
+}
+
+//all cells within Depth links of Center, and the links among them
+type Neighbourhood struct {
+	Center Cell
+	Depth  int
+	Cells  []Neighbour
+	Links  []Link
+}
+
+//the cells of a neighbourhood at the same distance from its center
+type NeighbourhoodLevel struct {
+	Distance int
+	Cells    []Cell
+}
+
+//groups the cells of the neighbourhood by their distance to the center, closest first
+func (n Neighbourhood) Levels() []NeighbourhoodLevel {
+	levels := make([]NeighbourhoodLevel, n.Depth)
+	for i := range levels {
+		levels[i].Distance = i + 1
+	}
+	for _, neighbour := range n.Cells {
+		if neighbour.Distance < 1 || neighbour.Distance > n.Depth {
+			continue
+		}
+		levels[neighbour.Distance-1].Cells = append(levels[neighbour.Distance-1].Cells, neighbour.Cell)
+	}
+	return levels
+}
+
+//cells that are poorly connected to the rest of the labyrinth
+type OrphanReport struct {
+	Room       string
+	NoLinks    []Cell
+	NoSources  []Cell
+	SingleLink []Cell
+}
+
+//someone who can log into the labyrinth
+type User struct {
+	Id           string
+	Username     string
+	PasswordHash string
+	Role         Role
+	Create_time  time.Time
+}
+
+//what a user is allowed to do in the labyrinth, each role can do everything
+//the ones before it can
+type Role string
+
+const (
+	//visitors who have not logged in
+	RoleAnyone Role = ""
+	//can view cells and rooms
+	RoleReader Role = "reader"
+	//can create, edit and link cells
+	RoleEditor Role = "editor"
+	//can manage rooms, sources, users and deletions
+	RoleAdmin Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleAnyone: 0, RoleReader: 1, RoleEditor: 2, RoleAdmin: 3}
+
+//the roles users can be given
+var Roles = []Role{RoleReader, RoleEditor, RoleAdmin}
+
+//checks whether someone with the role can do what the required role can
+func (r Role) Allows(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+//returns the role named, or an error if no user can have it
+func ParseRole(name string) (Role, error) {
+	for _, role := range Roles {
+		if string(role) == name {
+			return role, nil
+		}
+	}
+	return RoleAnyone, errors.New("Unknown role: " + name)
+}
+
+//passwords shorter than this are refused
+const MinPasswordLength = 8
+
+//returns the bcrypt hash of the password, the only thing stored of it
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+//checks the password against the hash stored for the user
+func (u User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+var (
+	missingUserOnce sync.Once
+	missingUserHash []byte
+)
+
+//checks the password against a made up hash and fails, so looking for a user
+//that does not exist takes as long as checking the password of one that does
+func CheckMissingUserPassword(password string) bool {
+	missingUserOnce.Do(func() {
+		missingUserHash, _ = bcrypt.GenerateFromPassword([]byte("there is no such user"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(missingUserHash, []byte(password))
+	return false
+}
+
+//what an API token lets its scripts do on behalf of its user
+type Scope string
+
+const (
+	//can view whatever the user can view
+	ScopeRead Scope = "read"
+	//can also change whatever the user can change
+	ScopeWrite Scope = "write"
+)
+
+//the scopes tokens can be given
+var Scopes = []Scope{ScopeRead, ScopeWrite}
+
+//returns the scope named, or an error if tokens cannot have it
+func ParseScope(name string) (Scope, error) {
+	for _, scope := range Scopes {
+		if string(scope) == name {
+			return scope, nil
+		}
+	}
+	return "", errors.New("Unknown scope: " + name)
+}
+
+//a long-lived token scripts use to act as a user, only its hash is stored
+type APIToken struct {
+	Id          string
+	UserId      string
+	Name        string
+	Scopes      []Scope
+	Create_time time.Time
+	//nil until the token is used
+	Last_used_time *time.Time
+}
+
+//checks whether the token was given the scope
+func (t APIToken) Has(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+//the role the user has when acting through the token, which cannot change
+//anything without the write scope
+func (t APIToken) Cap(role Role) Role {
+	if !t.Has(ScopeWrite) && role.Allows(RoleEditor) {
+		return RoleReader
+	}
+	return role
+}
+
+//every token starts with this so it is easy to spot in leaked files
+const tokenPrefix = "lob_"
+
+//returns a new random token, to be shown once to its user, and its hash
+func GenerateToken() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, HashToken(token), nil
+}
+
+//returns the hash tokens are stored and looked up by
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+//a change made to the labyrinth, recorded in its audit log
+type AuditEntry struct {
+	Id int64
+	//empty for changes made from the command line
+	UserId   string
+	Username string
+	Action   string
+	//the id of the cell, or the room or user, that changed
+	Target string
+	//JSON of the values changed, empty when there were none
+	Before      string
+	After       string
+	Create_time time.Time
+}
+
+//which entries of the audit log to list, every one by default
+type AuditFilter struct {
+	Username string
+	//entries changing the cell, or linking it to another one
+	CellId string
+	//entries from From, and before To, when not zero
+	From time.Time
+	To   time.Time
+	//the newest Limit entries, all of them when zero
+	Limit int
+}
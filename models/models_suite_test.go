@@ -0,0 +1,77 @@
+package models_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+)
+
+func TestModels(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Models Suite")
+}
+
+var _ = Describe("Cell", func() {
+	var cell models.Cell
+
+	Describe("Reading wiki links", func() {
+		BeforeEach(func() {
+			cell = models.Cell{Body: "Goes to [[The Aleph]] and to [[72aed05b|the first idea]], not to [single brackets]"}
+		})
+		It("should find links by title and by id", func() {
+			Expect(cell.WikiLinks()).To(Equal([]models.WikiLink{
+				models.WikiLink{Target: "The Aleph", Label: "The Aleph"},
+				models.WikiLink{Target: "72aed05b", Label: "the first idea", ById: true},
+			}))
+		})
+		It("should render them as links to the cells", func() {
+			html := cell.HTMLBody()
+			Expect(html).To(ContainSubstring(`<a href="/wiki/The%20Aleph" rel="nofollow">The Aleph</a>`))
+			Expect(html).To(ContainSubstring(`<a href="/cell/72aed05b" rel="nofollow">the first idea</a>`))
+			Expect(html).To(ContainSubstring(`[single brackets]`))
+		})
+		It("should keep the links within the labyrinth serving them", func() {
+			html := cell.HTMLBodyAt("/team")
+			Expect(html).To(ContainSubstring(`<a href="/team/wiki/The%20Aleph" rel="nofollow">`))
+			Expect(html).To(ContainSubstring(`<a href="/team/cell/72aed05b" rel="nofollow">`))
+		})
+	})
+
+	Describe("Roles", func() {
+		It("should allow each role what the lower ones can do", func() {
+			Expect(models.RoleAdmin.Allows(models.RoleEditor)).To(BeTrue())
+			Expect(models.RoleEditor.Allows(models.RoleEditor)).To(BeTrue())
+			Expect(models.RoleReader.Allows(models.RoleEditor)).To(BeFalse())
+			Expect(models.RoleAnyone.Allows(models.RoleReader)).To(BeFalse())
+			Expect(models.RoleAnyone.Allows(models.RoleAnyone)).To(BeTrue())
+		})
+		It("should not allow anything beyond reading to unknown roles", func() {
+			Expect(models.Role("minotaur").Allows(models.RoleReader)).To(BeFalse())
+			_, err := models.ParseRole("minotaur")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("API tokens", func() {
+		It("should generate distinct tokens and hash them the same way every time", func() {
+			token, hash, err := models.GenerateToken()
+			Expect(err).To(BeNil())
+			other, _, err := models.GenerateToken()
+			Expect(err).To(BeNil())
+			Expect(token).To(HavePrefix("lob_"))
+			Expect(token).NotTo(Equal(other))
+			Expect(models.HashToken(token)).To(Equal(hash))
+			Expect(hash).NotTo(ContainSubstring(token))
+		})
+		It("should not let read tokens change anything", func() {
+			read := models.APIToken{Scopes: []models.Scope{models.ScopeRead}}
+			write := models.APIToken{Scopes: []models.Scope{models.ScopeRead, models.ScopeWrite}}
+			Expect(read.Cap(models.RoleAdmin)).To(Equal(models.RoleReader))
+			Expect(write.Cap(models.RoleEditor)).To(Equal(models.RoleEditor))
+			Expect(write.Cap(models.RoleReader)).To(Equal(models.RoleReader))
+		})
+	})
+})
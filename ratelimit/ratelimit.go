@@ -0,0 +1,230 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//Limiter makes whoever keeps doing something, such as failing to log in,
+//wait longer and longer before doing it again
+type Limiter struct {
+	//events allowed before having to wait
+	Free int
+	//wait after the first event beyond the free ones, doubled on every following one
+	Base time.Duration
+	//longest wait, keys that reach it are locked out for this long
+	Max time.Duration
+	//one event is forgotten every Decay, none when zero
+	Decay time.Duration
+	//returns the current time, time.Now when nil
+	Now func() time.Time
+
+	mutex     sync.Mutex
+	records   map[string]*record
+	lastSweep time.Time
+}
+
+//what the limiter remembers of a key
+type record struct {
+	events  int
+	updated time.Time
+	//no events are allowed before this
+	until time.Time
+}
+
+func (l *Limiter) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+//forgets the events that decayed since the record was updated
+func (l *Limiter) decay(rec *record, now time.Time) {
+	if l.Decay <= 0 || rec.events == 0 {
+		rec.updated = now
+		return
+	}
+	forgotten := int(now.Sub(rec.updated) / l.Decay)
+	if forgotten >= rec.events {
+		rec.events = 0
+		rec.updated = now
+		return
+	}
+	rec.events -= forgotten
+	rec.updated = rec.updated.Add(time.Duration(forgotten) * l.Decay)
+}
+
+//returns how long the key has to wait before its next event, zero if it can go ahead
+func (l *Limiter) Wait(key string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	rec, ok := l.records[key]
+	if !ok {
+		return 0
+	}
+	if wait := rec.until.Sub(l.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+//records an event of the key and returns how long it has to wait before the next one
+func (l *Limiter) Record(key string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, wait := l.record(key, l.now())
+	return wait
+}
+
+func (l *Limiter) record(key string, now time.Time) (*record, time.Duration) {
+	l.sweep(now)
+	rec, ok := l.records[key]
+	if !ok {
+		rec = &record{updated: now}
+		l.records[key] = rec
+	}
+	l.decay(rec, now)
+	rec.events++
+	if rec.events <= l.Free {
+		return rec, 0
+	}
+	wait := l.Base
+	for i := l.Free + 1; i < rec.events && wait < l.Max; i++ {
+		wait *= 2
+	}
+	if wait > l.Max {
+		wait = l.Max
+	}
+	rec.until = now.Add(wait)
+	return rec, wait
+}
+
+//Reservation is an event recorded before knowing whether it counts
+type Reservation struct {
+	limiter *Limiter
+	key     string
+	//when the key could have its next event before the reservation, and after it
+	before, after time.Time
+}
+
+//Reserve records an event of the key unless it has to wait, in which case it
+//returns how long and records nothing; waiting and recording at once keeps
+//a burst of requests from all going ahead before any of them is recorded
+func (l *Limiter) Reserve(key string) (*Reservation, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	reservation := &Reservation{limiter: l, key: key}
+	if rec, ok := l.records[key]; ok {
+		if wait := rec.until.Sub(now); wait > 0 {
+			return nil, wait
+		}
+		reservation.before = rec.until
+	}
+	rec, _ := l.record(key, now)
+	reservation.after = rec.until
+	return reservation, 0
+}
+
+//Cancel forgets the reserved event, as it turned out not to count
+func (r *Reservation) Cancel() {
+	l := r.limiter
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	rec, ok := l.records[r.key]
+	if !ok {
+		return
+	}
+	if rec.events > 0 {
+		rec.events--
+	}
+	//the wait the event made, unless a later one changed it
+	if rec.until.Equal(r.after) {
+		rec.until = r.before
+	}
+}
+
+//forgets every event of the key
+func (l *Limiter) Reset(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.records, key)
+}
+
+//drops the records that have nothing left to remember, at most once every Decay
+func (l *Limiter) sweep(now time.Time) {
+	if l.records == nil {
+		l.records = make(map[string]*record)
+		l.lastSweep = now
+		return
+	}
+	if l.Decay <= 0 || now.Sub(l.lastSweep) < l.Decay {
+		return
+	}
+	l.lastSweep = now
+	for key, rec := range l.records {
+		l.decay(rec, now)
+		if rec.events == 0 && !rec.until.After(now) {
+			delete(l.records, key)
+		}
+	}
+}
+
+//Key tells apart who makes a request, requests with an empty key are not limited
+type Key func(r *http.Request) string
+
+//ByAddress tells requests apart by the address they come from
+func ByAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+//ByForm tells requests apart by the value of a field of their form, such as a username
+func ByForm(field string) Key {
+	return func(r *http.Request) string {
+		return r.FormValue(field)
+	}
+}
+
+//Throttle refuses requests whose key has to wait, and records an event for
+//every request whose status counts; counts nil makes every request count.
+//The event is reserved before the request is served and cancelled if its
+//status does not count, so parallel requests cannot outrun the limiter
+func (l *Limiter) Throttle(key Key, counts func(status int) bool, refuse func(w http.ResponseWriter, r *http.Request, wait time.Duration)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k := key(r)
+			if k == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			reservation, wait := l.Reserve(k)
+			if wait > 0 {
+				refuse(w, r, wait)
+				return
+			}
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			if counts != nil && !counts(recorder.status) {
+				reservation.Cancel()
+			}
+		})
+	}
+}
+
+//remembers the status the handler answered with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,158 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dacero/labyrinth-of-babel/ratelimit"
+)
+
+func TestRatelimit(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ratelimit Suite")
+}
+
+var _ = Describe("Limiter", func() {
+	var (
+		now     time.Time
+		limiter *ratelimit.Limiter
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2021, 3, 22, 12, 0, 0, 0, time.UTC)
+		limiter = &ratelimit.Limiter{Free: 3, Base: time.Second, Max: time.Minute, Decay: 10 * time.Minute,
+			Now: func() time.Time { return now }}
+	})
+
+	Describe("Backing off", func() {
+		It("should let the free events through", func() {
+			for i := 0; i < 3; i++ {
+				Expect(limiter.Record("funes")).To(BeZero())
+			}
+			Expect(limiter.Wait("funes")).To(BeZero())
+		})
+		It("should double the wait on every event beyond the free ones", func() {
+			for i := 0; i < 3; i++ {
+				limiter.Record("funes")
+			}
+			Expect(limiter.Record("funes")).To(Equal(time.Second))
+			Expect(limiter.Record("funes")).To(Equal(2 * time.Second))
+			Expect(limiter.Record("funes")).To(Equal(4 * time.Second))
+			Expect(limiter.Wait("funes")).To(Equal(4 * time.Second))
+			now = now.Add(3 * time.Second)
+			Expect(limiter.Wait("funes")).To(Equal(time.Second))
+		})
+		It("should lock out keys that reach the longest wait", func() {
+			for i := 0; i < 20; i++ {
+				limiter.Record("funes")
+			}
+			Expect(limiter.Wait("funes")).To(Equal(time.Minute))
+		})
+		It("should keep keys apart", func() {
+			for i := 0; i < 5; i++ {
+				limiter.Record("funes")
+			}
+			Expect(limiter.Wait("borges")).To(BeZero())
+		})
+	})
+
+	Describe("Forgetting", func() {
+		It("should forget one event every decay", func() {
+			for i := 0; i < 4; i++ {
+				limiter.Record("funes")
+			}
+			now = now.Add(20 * time.Minute)
+			Expect(limiter.Wait("funes")).To(BeZero())
+			//two of the four events are forgotten, two more are free
+			Expect(limiter.Record("funes")).To(BeZero())
+			Expect(limiter.Record("funes")).To(Equal(time.Second))
+		})
+		It("should forget every event when reset", func() {
+			for i := 0; i < 5; i++ {
+				limiter.Record("funes")
+			}
+			limiter.Reset("funes")
+			Expect(limiter.Wait("funes")).To(BeZero())
+		})
+	})
+
+	Describe("Throttling requests", func() {
+		var (
+			handler http.Handler
+			refused time.Duration
+		)
+		login := func(username string, password string) int {
+			form := url.Values{"username": {username}, "password": {password}}
+			req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			return rr.Code
+		}
+		BeforeEach(func() {
+			refused = 0
+			failed := func(status int) bool { return status == http.StatusUnauthorized }
+			refuse := func(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+				refused = wait
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+			handler = limiter.Throttle(ratelimit.ByForm("username"), failed, refuse)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.PostFormValue("password") != "labyrinth" {
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+			}))
+		})
+		It("should only count the requests that fail", func() {
+			for i := 0; i < 10; i++ {
+				Expect(login("funes", "labyrinth")).To(Equal(http.StatusOK))
+			}
+		})
+		It("should refuse requests until the wait is over", func() {
+			for i := 0; i < 4; i++ {
+				Expect(login("funes", "minotaur")).To(Equal(http.StatusUnauthorized))
+			}
+			Expect(login("funes", "labyrinth")).To(Equal(http.StatusTooManyRequests))
+			Expect(refused).To(Equal(time.Second))
+			now = now.Add(time.Second)
+			Expect(login("funes", "labyrinth")).To(Equal(http.StatusOK))
+		})
+		It("should count the requests still being served", func() {
+			//every failing login tries again before it has answered, as a burst would
+			served := 0
+			handler = limiter.Throttle(ratelimit.ByForm("username"), func(status int) bool { return status == http.StatusUnauthorized },
+				func(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+					w.WriteHeader(http.StatusTooManyRequests)
+				})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served++
+				if served < 10 {
+					login("funes", "minotaur")
+				}
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			Expect(login("funes", "minotaur")).To(Equal(http.StatusUnauthorized))
+			Expect(served).To(Equal(4))
+			Expect(limiter.Wait("funes")).To(Equal(time.Second))
+		})
+		It("should not hold against a key the requests that do not count", func() {
+			for i := 0; i < 3; i++ {
+				Expect(login("funes", "minotaur")).To(Equal(http.StatusUnauthorized))
+			}
+			Expect(login("funes", "labyrinth")).To(Equal(http.StatusOK))
+			Expect(limiter.Wait("funes")).To(BeZero())
+			Expect(login("funes", "minotaur")).To(Equal(http.StatusUnauthorized))
+			Expect(limiter.Wait("funes")).To(Equal(time.Second))
+		})
+		It("should tell apart requests by their address", func() {
+			req := httptest.NewRequest("GET", "/searchCells", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			Expect(ratelimit.ByAddress(req)).To(Equal("192.0.2.1"))
+		})
+	})
+})
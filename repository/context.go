@@ -0,0 +1,22 @@
+package repository
+
+import "context"
+
+type contextKey int
+
+const lobKey contextKey = iota
+
+//NewContext returns a context carrying the repository as the one making the
+//request sees it, such as the one WithActor returns for a user
+func NewContext(ctx context.Context, lob LobRepository) context.Context {
+	return context.WithValue(ctx, lobKey, lob)
+}
+
+//FromContext returns the repository the context carries, or lob when it
+//carries none
+func FromContext(ctx context.Context, lob LobRepository) LobRepository {
+	if carried, ok := ctx.Value(lobKey).(LobRepository); ok && carried != nil {
+		return carried
+	}
+	return lob
+}
@@ -2,10 +2,13 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"log"
 	"os"
+	"regexp"
 	"time"
 	"errors"
+	"sort"
 	"strings"
 
 	"github.com/dacero/labyrinth-of-babel/models"
@@ -17,35 +20,99 @@ import (
 type LobRepository interface {
 	//gets a new cell from its id
 	GetCell(id string) (models.Cell, error)
+	//gets the oldest cell with that title
+	GetCellByTitle(title string) (models.Cell, error)
 	//updates the cell with new content
 	UpdateCell(cell models.Cell) (int64, error)
 	//adds and removes a source from a cell, returns the cell with updated sources
 	AddSourceToCell(cellId string, source models.Source) (models.Cell, error)
 	RemoveSourceFromCell(cellId string, source models.Source) (models.Cell, error)
+	//adds and removes a tag from a cell, returns the cell with updated tags
+	AddTagToCell(cellId string, tag models.Tag) (models.Cell, error)
+	RemoveTagFromCell(cellId string, tag models.Tag) (models.Cell, error)
 	//links or unlink 2 cells
 	LinkCells(idA string, idB string) (error)
 	UnlinkCells(idA string, idB string) (error)
 	//checks if two cells are linked
 	CheckLink(idA string, idB string) (bool, error)
+	//returns the cells whose body mentions the title or an alias of a cell, as
+	//whole words, but are not linked to it
+	GetUnlinkedMentions(id string) ([]models.Cell, error)
+	//returns all cells within depth links of a cell, with their distances and the links among them
+	GetNeighbourhood(id string, depth int) (models.Neighbourhood, error)
 	//creates a new cell and returns its new id
 	NewCell(c models.Cell) (string, error)
+	//deletes a cell along with its links, sources and tags
+	DeleteCell(id string) error
 	//Returns a full list of all rooms in the labyrinth
 	ListRooms() ([]models.CollectionOfCells, error)
 	//Returns all cells in a room
 	ListCellsInRoom(room string) ([]models.Cell, error)
+	//Returns all cells with a tag
+	ListCellsWithTag(tag string) ([]models.Cell, error)
+	//Returns every cell in the labyrinth with its sources, but not its links
+	ListCells() ([]models.Cell, error)
+	//Returns every link in the labyrinth
+	ListLinks() ([]models.Link, error)
+	//Returns the cells linked to each of the cells passed, the sources and
+	//the tags of each of them, indexed by their id, in a single query each
+	GetLinksOfCells(ids []string) (map[string][]models.Cell, error)
+	GetSourcesOfCells(ids []string) (map[string][]models.Source, error)
+	GetTagsOfCells(ids []string) (map[string][]models.Tag, error)
+	//Returns the cells with each of the sources passed, indexed by the source
+	ListCellsWithSources(sources []string) (map[string][]models.Cell, error)
+	//Returns the cells in each of the rooms passed, indexed by the room
+	ListCellsInRooms(rooms []string) (map[string][]models.Cell, error)
+	//Returns cells without links, without sources or with a single link, in a room or in all of them if room is empty
+	ListOrphanCells(room string) (models.OrphanReport, error)
 	//searches for sources that contain the terms passed
 	SearchSources(term string) []models.Source
 	//searches for rooms that contain the terms passed
 	SearchRooms(term string) []string
+	//searches for tags that contain the terms passed
+	SearchTags(term string) []models.Tag
 	//searches for cells that contain the terms passed
 	SearchCells(term string) []models.Cell
+	//searches for cells that contain the terms passed and have all the tags passed
+	SearchCellsWithTags(term string, tags []string) []models.Cell
+	//creates a new user and returns its new id
+	NewUser(user models.User) (string, error)
+	//gets a user from its id or its username
+	GetUser(id string) (models.User, error)
+	GetUserByUsername(username string) (models.User, error)
+	//Returns every user, sorted by username
+	ListUsers() ([]models.User, error)
+	//changes what a user is allowed to do
+	SetUserRole(id string, role models.Role) error
+	//creates an API token for a user, storing only its hash, and returns its new id
+	NewAPIToken(token models.APIToken, hash string) (string, error)
+	//Returns the API tokens of a user, newest first
+	ListAPITokens(userId string) ([]models.APIToken, error)
+	//revokes one of the API tokens of a user
+	RevokeAPIToken(userId string, id string) error
+	//gets the API token with the hash passed and records it was used
+	UseAPIToken(hash string) (models.APIToken, error)
+	//makes a room and all its cells private, or public again
+	SetRoomPrivate(room string, private bool) error
+	//returns a view of the labyrinth without private cells and rooms, for
+	//visitors who have not logged in
+	Public() LobRepository
+	//returns a view of the labyrinth whose changes are recorded as made by the user
+	WithActor(user models.User) LobRepository
+	//Returns the entries of the audit log that pass the filter, newest first
+	ListAudit(filter models.AuditFilter) ([]models.AuditEntry, error)
 	//closes the database
 	Close()
 }
 
 func NewLobRepository() *lobRepository {
+	return NewLobRepositoryFor(os.Getenv("MYSQL_DATABASE"))
+}
+
+//returns the repository of the labyrinth kept in the database, each labyrinth
+//has its own database so their cells never mix
+func NewLobRepositoryFor(database string) *lobRepository {
 	password := os.Getenv("MYSQL_ROOT_PASSWORD")
-	database := os.Getenv("MYSQL_DATABASE")
 	newDB, err := sql.Open("mysql", "root:"+password+"@tcp(mysql:3306)/"+database+"?parseTime=true")
 	if err != nil {
 		log.Panic(err)
@@ -55,6 +122,31 @@ func NewLobRepository() *lobRepository {
 
 type lobRepository struct {
 	db *sql.DB
+	//hides private cells and rooms
+	public bool
+	//the user making the changes, nil for the command line
+	actor *models.User
+}
+
+func (r *lobRepository) Public() LobRepository {
+	return &lobRepository{db: r.db, public: true, actor: r.actor}
+}
+
+func (r *lobRepository) WithActor(user models.User) LobRepository {
+	return &lobRepository{db: r.db, public: r.public, actor: &user}
+}
+
+//SQL telling whether the cell, under alias, is private by itself or by its room
+func privacyOf(alias string) string {
+	return "(" + alias + ".private = 1 OR " + alias + ".room IN (SELECT room FROM rooms WHERE private = 1))"
+}
+
+//SQL condition leaving out the private cells, under alias, from a public view
+func (r *lobRepository) visible(alias string) string {
+	if !r.public {
+		return ""
+	}
+	return " AND NOT " + privacyOf(alias)
 }
 
 func (r *lobRepository) getDB() *sql.DB {
@@ -68,20 +160,31 @@ func (r *lobRepository) Close() {
 func (r *lobRepository) GetCell(id string) (models.Cell, error) {
 	var cell models.Cell
 
-	row := r.getDB().QueryRow("SELECT id, title, body, room, create_time, update_time FROM cells WHERE id=?", id)
+	row := r.getDB().QueryRow("SELECT id, title, body, room, create_time, update_time, "+privacyOf("cells")+" FROM cells WHERE id=?"+r.visible("cells"), id)
 
-	err := row.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time)
+	err := row.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time, &cell.Private)
 	if err != nil {
 		log.Println(err)
 		return cell, err
 	}
 
 	cell.Sources = r.getCellSources(id)
+	cell.Tags = r.getCellTags(id)
 	cell.Links = r.getCellLinks(id)
 
 	return cell, nil
 }
 
+func (r *lobRepository) GetCellByTitle(title string) (models.Cell, error) {
+	var id string
+	row := r.getDB().QueryRow("SELECT id FROM cells WHERE title=?"+r.visible("cells")+" ORDER BY create_time LIMIT 1", strings.TrimSpace(title))
+	err := row.Scan(&id)
+	if err != nil {
+		return models.Cell{}, err
+	}
+	return r.GetCell(id)
+}
+
 func (r *lobRepository) getCellSources(id string) []models.Source {
 	var sources []models.Source
 
@@ -108,18 +211,44 @@ func (r *lobRepository) getCellSources(id string) []models.Source {
 	return sources
 }
 
+func (r *lobRepository) getCellTags(id string) []models.Tag {
+	var tags []models.Tag
+
+	rows, err := r.getDB().Query(`SELECT tags_tag
+		FROM cells_tags
+		WHERE cells_id=?
+		ORDER BY tags_tag`, id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag string
+		err := rows.Scan(&tag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tags = append(tags, models.Tag{Tag: tag})
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return tags
+}
+
 func (r *lobRepository) getCellLinks(id string) []models.Cell {
 	var links []models.Cell
 
-	rows, err := r.getDB().Query(`SELECT c.id, c.title, c.body, c.create_time, c.update_time, c.room 
+	rows, err := r.getDB().Query(`SELECT c.id, c.title, c.body, c.create_time, c.update_time, c.room, `+privacyOf("c")+` private
 		FROM cells_links l, cells c 
 		WHERE l.cells_a = c.id
-		AND l.cells_b = ?
+		AND l.cells_b = ?`+r.visible("c")+`
 		UNION
-		SELECT c.id, c.title, c.body, c.create_time, c.update_time, c.room
+		SELECT c.id, c.title, c.body, c.create_time, c.update_time, c.room, `+privacyOf("c")+` private
 		FROM cells_links l, cells c 
 		WHERE l.cells_b = c.id
-		AND l.cells_a = ?
+		AND l.cells_a = ?`+r.visible("c")+`
 		ORDER BY create_time DESC;`, id, id)
 	if err != nil {
 		log.Fatal(err)
@@ -128,7 +257,7 @@ func (r *lobRepository) getCellLinks(id string) []models.Cell {
 
 	for rows.Next() {
 		var cell models.Cell
-		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Create_time, &cell.Update_time, &cell.Room)
+		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Create_time, &cell.Update_time, &cell.Room, &cell.Private)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -153,12 +282,21 @@ func (r *lobRepository) UpdateCell(cell models.Cell) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	before, _ := r.GetCell(cell.Id)
 	//update the cell
-	result, err := r.getDB().Exec("UPDATE cells SET title = ?, body = ?, room = ?, update_time = ? where id = ?", cell.Title, cell.Body, cell.Room, time.Now(), cell.Id)
+	result, err := r.getDB().Exec("UPDATE cells SET title = ?, body = ?, room = ?, private = ?, update_time = ? where id = ?", cell.Title, cell.Body, cell.Room, cell.Private, time.Now(), cell.Id)
 	if err != nil {
 		return 0, err
 	}
-	return result.RowsAffected()
+	updated, err := result.RowsAffected()
+	if err != nil || updated == 0 {
+		return updated, err
+	}
+	//the sources of a cell are not updated with it, but added and removed on their own
+	after := cell
+	after.Sources = before.Sources
+	r.audit("update_cell", cell.Id, cellValues(before), cellValues(after))
+	return updated, r.syncWikiLinks(cell)
 }
 
 func (r *lobRepository) LinkCells(idA string, idB string) (error) {
@@ -179,7 +317,83 @@ func (r *lobRepository) LinkCells(idA string, idB string) (error) {
 		return err
 	}
 	_, err = stmt.Exec(idA, idB)
-	return err
+	if err != nil {
+		return err
+	}
+	r.audit("link_cells", idA, nil, linkValues(idB))
+	return nil
+}
+
+//links the cell with the cells in its [[wiki links]] and removes the wiki links
+//no longer in its body, links created by hand are never touched
+func (r *lobRepository) syncWikiLinks(cell models.Cell) error {
+	targets := []string{}
+	for _, wikiLink := range cell.WikiLinks() {
+		var targetId string
+		var row *sql.Row
+		if wikiLink.ById {
+			row = r.getDB().QueryRow("SELECT id FROM cells WHERE id=?", wikiLink.Target)
+		} else {
+			row = r.getDB().QueryRow("SELECT id FROM cells WHERE title=? ORDER BY create_time LIMIT 1", wikiLink.Target)
+		}
+		err := row.Scan(&targetId)
+		if err == sql.ErrNoRows {
+			//links to cells that do not exist yet are only rendered
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if targetId == cell.Id {
+			continue
+		}
+		targets = append(targets, targetId)
+		linked, err := r.CheckLink(cell.Id, targetId)
+		if err != nil {
+			return err
+		}
+		if linked {
+			continue
+		}
+		_, err = r.getDB().Exec("INSERT INTO cells_links(cells_a, cells_b, wiki) VALUES (?, ?, 1)", cell.Id, targetId)
+		if err != nil {
+			return err
+		}
+		r.audit("link_cells", cell.Id, nil, wikiLinkValues(targetId))
+	}
+
+	staleStr := "SELECT cells_b FROM cells_links WHERE cells_a = ? AND wiki = 1"
+	vals := []interface{}{cell.Id}
+	if len(targets) > 0 {
+		staleStr += " AND cells_b NOT IN (" + placeholders(len(targets)) + ")"
+		vals = append(vals, stringArgs(targets)...)
+	}
+	rows, err := r.getDB().Query(staleStr, vals...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var stale []string
+	for rows.Next() {
+		var targetId string
+		if err := rows.Scan(&targetId); err != nil {
+			return err
+		}
+		stale = append(stale, targetId)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, targetId := range stale {
+		result, err := r.getDB().Exec("DELETE FROM cells_links WHERE cells_a = ? AND cells_b = ? AND wiki = 1", cell.Id, targetId)
+		if err != nil {
+			return err
+		}
+		if unlinked, err := result.RowsAffected(); err == nil && unlinked > 0 {
+			r.audit("unlink_cells", cell.Id, wikiLinkValues(targetId), nil)
+		}
+	}
+	return nil
 }
 
 func (r *lobRepository) UnlinkCells(idA string, idB string) (error) {
@@ -188,8 +402,14 @@ func (r *lobRepository) UnlinkCells(idA string, idB string) (error) {
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Exec(idA, idB, idB, idA)
-	return err
+	result, err := stmt.Exec(idA, idB, idB, idA)
+	if err != nil {
+		return err
+	}
+	if unlinked, err := result.RowsAffected(); err == nil && unlinked > 0 {
+		r.audit("unlink_cells", idA, linkValues(idB), nil)
+	}
+	return nil
 }
 
 func (r *lobRepository) CheckLink(idA string, idB string) (bool, error) {
@@ -218,6 +438,7 @@ func (r *lobRepository) AddSourceToCell(cellId string, source models.Source) (mo
 		cell, _ := r.GetCell(cellId) // unnecessary!!!
 		return cell, err
 	}
+	r.audit("add_source", cellId, nil, map[string]string{"source": strings.TrimSpace(source.Source)})
 	return r.GetCell(cellId)
 }
 
@@ -227,11 +448,46 @@ func (r *lobRepository) RemoveSourceFromCell(cellId string, source models.Source
 		cell, _ := r.GetCell(cellId) // unnecessary!!!
 		return cell, err
 	}
-	_, err = stmt.Exec(cellId, strings.TrimSpace(source.Source))
+	result, err := stmt.Exec(cellId, strings.TrimSpace(source.Source))
 	if err != nil {
 		cell, _ := r.GetCell(cellId) // unnecessary!!!
 		return cell, err
 	}
+	if removed, err := result.RowsAffected(); err == nil && removed > 0 {
+		r.audit("remove_source", cellId, map[string]string{"source": strings.TrimSpace(source.Source)}, nil)
+	}
+	return r.GetCell(cellId)
+}
+
+func (r *lobRepository) AddTagToCell(cellId string, tag models.Tag) (models.Cell, error) {
+	trimmedTag := strings.TrimSpace(tag.String())
+	if trimmedTag == "" {
+		return r.GetCell(cellId)
+	}
+	//create the tag if not exists
+	_, err := r.getDB().Exec("INSERT IGNORE INTO tags(tag) VALUES (?)", trimmedTag)
+	if err != nil {
+		return models.Cell{}, err
+	}
+	//tag the cell if not already
+	result, err := r.getDB().Exec("INSERT IGNORE INTO cells_tags(cells_id, tags_tag) VALUES (?, ?)", cellId, trimmedTag)
+	if err != nil {
+		return models.Cell{}, err
+	}
+	if added, err := result.RowsAffected(); err == nil && added > 0 {
+		r.audit("add_tag", cellId, nil, map[string]string{"tag": trimmedTag})
+	}
+	return r.GetCell(cellId)
+}
+
+func (r *lobRepository) RemoveTagFromCell(cellId string, tag models.Tag) (models.Cell, error) {
+	result, err := r.getDB().Exec("DELETE FROM cells_tags WHERE cells_id=? AND tags_tag=?", cellId, strings.TrimSpace(tag.String()))
+	if err != nil {
+		return models.Cell{}, err
+	}
+	if removed, err := result.RowsAffected(); err == nil && removed > 0 {
+		r.audit("remove_tag", cellId, map[string]string{"tag": strings.TrimSpace(tag.String())}, nil)
+	}
 	return r.GetCell(cellId)
 }
 
@@ -252,11 +508,11 @@ func (r *lobRepository) NewCell(cell models.Cell) (string, error) {
 		return "", err
 	}
 	//insert the cell
-	stmt, err := r.getDB().Prepare("INSERT INTO cells(id, title, body, room) VALUES (?, ?, ?, ?)")
+	stmt, err := r.getDB().Prepare("INSERT INTO cells(id, title, body, room, private) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
 		return "", err
 	}
-	_, err = stmt.Exec(cellId, strings.TrimSpace(cell.Title), strings.TrimSpace(cell.Body), strings.TrimSpace(cell.Room))
+	_, err = stmt.Exec(cellId, strings.TrimSpace(cell.Title), strings.TrimSpace(cell.Body), strings.TrimSpace(cell.Room), cell.Private)
 	if err != nil {
 		return "", err
 	}
@@ -270,9 +526,46 @@ func (r *lobRepository) NewCell(cell models.Cell) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	//link the cells mentioned as [[wiki links]]
+	cell.Id = cellId
+	r.audit("new_cell", cellId, nil, cellValues(cell))
+	err = r.syncWikiLinks(cell)
+	if err != nil {
+		return "", err
+	}
 	return cellId, nil
 }
 
+func (r *lobRepository) DeleteCell(id string) error {
+	before, err := r.GetCell(id)
+	if err != nil {
+		return err
+	}
+	tx, err := r.getDB().Begin()
+	if err != nil {
+		return err
+	}
+	//everything pointing to the cell goes first
+	statements := []string{
+		"DELETE FROM cells_links WHERE cells_a = ?",
+		"DELETE FROM cells_links WHERE cells_b = ?",
+		"DELETE FROM cells_sources WHERE cells_id = ?",
+		"DELETE FROM cells_tags WHERE cells_id = ?",
+		"DELETE FROM cells WHERE id = ?",
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	r.audit("delete_cell", id, cellValues(before), nil)
+	return nil
+}
+
 func (r *lobRepository) insertSources(sources []models.Source) error {
 	insertStr := "INSERT IGNORE INTO sources(source) VALUES "
 	vals := []interface{}{}
@@ -338,9 +631,16 @@ func (r *lobRepository) linkSources(cellId string, sources []models.Source) erro
 func (r *lobRepository) SearchSources(term string) []models.Source {
 	var sources []models.Source
 	
-	rows, err := r.getDB().Query(`SELECT source 
+	query := `SELECT source 
 		FROM sources
-		WHERE source LIKE ?`, "%" + term + "%")
+		WHERE source LIKE ?`
+	if r.public {
+		//only the sources of cells visitors can see
+		query += ` AND source IN (SELECT cs.sources_source
+			FROM cells_sources cs, cells c
+			WHERE cs.cells_id = c.id` + r.visible("c") + `)`
+	}
+	rows, err := r.getDB().Query(query, "%" + term + "%")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -363,9 +663,13 @@ func (r *lobRepository) SearchSources(term string) []models.Source {
 func (r *lobRepository) SearchRooms(term string) []string {
 	var rooms []string
 	
-	rows, err := r.getDB().Query(`SELECT room 
+	query := `SELECT room 
 		FROM rooms
-		WHERE room LIKE ?`, "%" + term + "%")
+		WHERE room LIKE ?`
+	if r.public {
+		query += " AND private = 0"
+	}
+	rows, err := r.getDB().Query(query, "%" + term + "%")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -385,11 +689,56 @@ func (r *lobRepository) SearchRooms(term string) []string {
 	return rooms
 }
 
+func (r *lobRepository) SearchTags(term string) []models.Tag {
+	var tags []models.Tag
+	
+	query := `SELECT tag 
+		FROM tags
+		WHERE tag LIKE ?`
+	if r.public {
+		//only the tags of cells visitors can see
+		query += ` AND tag IN (SELECT ct.tags_tag
+			FROM cells_tags ct, cells c
+			WHERE ct.cells_id = c.id` + r.visible("c") + `)`
+	}
+	rows, err := r.getDB().Query(query, "%" + term + "%")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag string
+		err := rows.Scan(&tag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tags = append(tags, models.Tag{Tag: tag})
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return tags
+}
+
 func (r *lobRepository) SearchCells(term string) []models.Cell {
+	return r.SearchCellsWithTags(term, nil)
+}
+
+func (r *lobRepository) SearchCellsWithTags(term string, tags []string) []models.Cell {
 	var cells []models.Cell
-	rows, err := r.getDB().Query(`SELECT id, title, body, create_time, update_time, room
+	query := `SELECT id, title, body, create_time, update_time, room, `+privacyOf("cells")+` private
 		FROM cells
-		WHERE title LIKE ? OR body LIKE ?`, "%" + term + "%", "%" + term + "%")
+		WHERE (title LIKE ? OR body LIKE ?)`+r.visible("cells")
+	vals := []interface{}{"%" + term + "%", "%" + term + "%"}
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			continue
+		}
+		query += " AND id IN (SELECT cells_id FROM cells_tags WHERE tags_tag = ?)"
+		vals = append(vals, strings.TrimSpace(tag))
+	}
+	rows, err := r.getDB().Query(query, vals...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -397,7 +746,7 @@ func (r *lobRepository) SearchCells(term string) []models.Cell {
 
 	for rows.Next() {
 		var cell models.Cell
-		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Create_time, &cell.Update_time, &cell.Room)
+		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Create_time, &cell.Update_time, &cell.Room, &cell.Private)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -412,10 +761,10 @@ func (r *lobRepository) SearchCells(term string) []models.Cell {
 func (r *lobRepository) ListRooms() ([]models.CollectionOfCells, error) {
 	var rooms []models.CollectionOfCells
 	
-	rows, err := r.getDB().Query(`SELECT rooms.room, COUNT(*), MIN(create_time) create_time 
+	rows, err := r.getDB().Query(`SELECT rooms.room, rooms.private, COUNT(*), MIN(create_time) create_time 
 		FROM rooms, cells
-		WHERE rooms.room = cells.room
-		GROUP BY rooms.room
+		WHERE rooms.room = cells.room`+r.visible("cells")+`
+		GROUP BY rooms.room, rooms.private
 		ORDER BY create_time DESC`)
 	if err != nil {
 		return rooms, err
@@ -424,7 +773,7 @@ func (r *lobRepository) ListRooms() ([]models.CollectionOfCells, error) {
 
 	for rows.Next() {
 		var room models.CollectionOfCells
-		err := rows.Scan(&room.Name, &room.CellCount, &room.Create_time)
+		err := rows.Scan(&room.Name, &room.Private, &room.CellCount, &room.Create_time)
 		if err != nil {
 			return rooms, err
 		}
@@ -439,9 +788,9 @@ func (r *lobRepository) ListRooms() ([]models.CollectionOfCells, error) {
 func (r *lobRepository) ListCellsInRoom(room string) ([]models.Cell, error) {
 	var cells []models.Cell
 	
-	rows, err := r.getDB().Query(`SELECT id, title, body, room, create_time, update_time 
+	rows, err := r.getDB().Query(`SELECT id, title, body, room, create_time, update_time, `+privacyOf("cells")+` private
 		FROM cells 
-		WHERE room=?
+		WHERE room=?`+r.visible("cells")+`
 		ORDER BY update_time DESC`, room)
 	if err != nil {
 		return cells, err
@@ -450,7 +799,7 @@ func (r *lobRepository) ListCellsInRoom(room string) ([]models.Cell, error) {
 	
 	for rows.Next() {
 		var cell models.Cell
-		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time)
+		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time, &cell.Private)
 		if err != nil {
 			return cells, err
 		}
@@ -462,3 +811,815 @@ func (r *lobRepository) ListCellsInRoom(room string) ([]models.Cell, error) {
 	
 	return cells, nil
 }	
+
+
+func (r *lobRepository) ListCellsWithTag(tag string) ([]models.Cell, error) {
+	var cells []models.Cell
+
+	rows, err := r.getDB().Query(`SELECT c.id, c.title, c.body, c.room, c.create_time, c.update_time, `+privacyOf("c")+` private
+		FROM cells c, cells_tags ct
+		WHERE ct.cells_id = c.id
+		AND ct.tags_tag = ?`+r.visible("c")+`
+		ORDER BY c.update_time DESC`, tag)
+	if err != nil {
+		return cells, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cell models.Cell
+		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time, &cell.Private)
+		if err != nil {
+			return cells, err
+		}
+		cells = append(cells, cell)
+	}
+	if err := rows.Err(); err != nil {
+		return cells, err
+	}
+	return cells, nil
+}
+
+func (r *lobRepository) ListCells() ([]models.Cell, error) {
+	var cells []models.Cell
+
+	rows, err := r.getDB().Query(`SELECT id, title, body, room, create_time, update_time, `+privacyOf("cells")+` private
+		FROM cells
+		WHERE 1 = 1`+r.visible("cells")+`
+		ORDER BY create_time`)
+	if err != nil {
+		return cells, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cell models.Cell
+		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time, &cell.Private)
+		if err != nil {
+			return cells, err
+		}
+		cells = append(cells, cell)
+	}
+	if err := rows.Err(); err != nil {
+		return cells, err
+	}
+
+	sources, err := r.getAllCellSources()
+	if err != nil {
+		return cells, err
+	}
+	for i := range cells {
+		cells[i].Sources = sources[cells[i].Id]
+	}
+	return cells, nil
+}
+
+//returns the sources of every cell, indexed by cell id
+func (r *lobRepository) getAllCellSources() (map[string][]models.Source, error) {
+	sources := make(map[string][]models.Source)
+
+	rows, err := r.getDB().Query(`SELECT cells_id, sources_source FROM cells_sources ORDER BY sources_source`)
+	if err != nil {
+		return sources, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cellId, source string
+		err := rows.Scan(&cellId, &source)
+		if err != nil {
+			return sources, err
+		}
+		sources[cellId] = append(sources[cellId], models.Source{Source: source})
+	}
+	return sources, rows.Err()
+}
+
+func (r *lobRepository) ListLinks() ([]models.Link, error) {
+	var links []models.Link
+
+	rows, err := r.getDB().Query(`SELECT l.cells_a, l.cells_b FROM cells_links l, cells a, cells b
+		WHERE l.cells_a = a.id AND l.cells_b = b.id`+r.visible("a")+r.visible("b"))
+	if err != nil {
+		return links, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link models.Link
+		err := rows.Scan(&link.CellA, &link.CellB)
+		if err != nil {
+			return links, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (r *lobRepository) ListOrphanCells(room string) (models.OrphanReport, error) {
+	report := models.OrphanReport{Room: room}
+
+	rows, err := r.getDB().Query(`SELECT c.id, c.title, c.body, c.room, c.create_time, c.update_time, `+privacyOf("c")+` private,
+		(SELECT COUNT(*) FROM cells_links l WHERE l.cells_a = c.id OR l.cells_b = c.id) links,
+		(SELECT COUNT(*) FROM cells_sources cs WHERE cs.cells_id = c.id) sources
+		FROM cells c
+		WHERE (? = '' OR c.room = ?)`+r.visible("c")+`
+		ORDER BY c.update_time DESC`, room, room)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cell models.Cell
+		var links, sources int
+		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time, &cell.Private, &links, &sources)
+		if err != nil {
+			return report, err
+		}
+		if links == 0 {
+			report.NoLinks = append(report.NoLinks, cell)
+		}
+		if links == 1 {
+			report.SingleLink = append(report.SingleLink, cell)
+		}
+		if sources == 0 {
+			report.NoSources = append(report.NoSources, cell)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func (r *lobRepository) GetUnlinkedMentions(id string) ([]models.Cell, error) {
+	var cells []models.Cell
+
+	var title string
+	row := r.getDB().QueryRow("SELECT title FROM cells WHERE id=?"+r.visible("cells"), id)
+	err := row.Scan(&title)
+	if err != nil {
+		return cells, err
+	}
+	names, err := r.namesOf(id, title)
+	if err != nil {
+		return cells, err
+	}
+	//cells without a title nor aliases cannot be mentioned
+	if len(names) == 0 {
+		return cells, nil
+	}
+
+	//the bodies with any of the names are the candidates, of which only those
+	//with a name as a whole word mention the cell
+	var likes []string
+	args := []interface{}{id}
+	for _, name := range names {
+		likes = append(likes, "c.body LIKE ?")
+		args = append(args, "%"+escapeLike(name)+"%")
+	}
+	args = append(args, id, id)
+	rows, err := r.getDB().Query(`SELECT c.id, c.title, c.body, c.room, c.create_time, c.update_time, `+privacyOf("c")+` private
+		FROM cells c
+		WHERE c.id <> ?
+		AND (`+strings.Join(likes, " OR ")+`)`+r.visible("c")+`
+		AND NOT EXISTS (SELECT 1 FROM cells_links l
+			WHERE (l.cells_a = c.id AND l.cells_b = ?) OR (l.cells_a = ? AND l.cells_b = c.id))
+		ORDER BY c.update_time DESC`, args...)
+	if err != nil {
+		return cells, err
+	}
+	defer rows.Close()
+
+	var words []*regexp.Regexp
+	for _, name := range names {
+		words = append(words, wordPattern(name))
+	}
+	for rows.Next() {
+		var cell models.Cell
+		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time, &cell.Private)
+		if err != nil {
+			return cells, err
+		}
+		for _, word := range words {
+			if word.MatchString(cell.Body) {
+				cells = append(cells, cell)
+				break
+			}
+		}
+	}
+	return cells, rows.Err()
+}
+
+//the names a cell is mentioned by: its title and its aliases, the labels
+//other cells give it when linking to it by id, as "the memorious" in
+//[[<id>|the memorious]]
+func (r *lobRepository) namesOf(id string, title string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		name = strings.TrimSpace(name)
+		if name != "" && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			names = append(names, name)
+		}
+	}
+	add(title)
+	rows, err := r.getDB().Query("SELECT body FROM cells WHERE body LIKE ?"+r.visible("cells"), "%[["+escapeLike(id)+"|%")
+	if err != nil {
+		return names, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			return names, err
+		}
+		for _, link := range (models.Cell{Body: body}).WikiLinks() {
+			if link.ById && link.Target == id {
+				add(link.Label)
+			}
+		}
+	}
+	return names, rows.Err()
+}
+
+//escapes the wildcards of LIKE in the text
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+}
+
+//matches the text as a whole word, or words, in any case
+func wordPattern(text string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(^|[^\pL\pN_])` + regexp.QuoteMeta(text) + `($|[^\pL\pN_])`)
+}
+
+func (r *lobRepository) GetNeighbourhood(id string, depth int) (models.Neighbourhood, error) {
+	neighbourhood := models.Neighbourhood{Depth: depth}
+	if depth < 1 {
+		return neighbourhood, errors.New("Depth must be at least 1")
+	}
+	center, err := r.GetCell(id)
+	if err != nil {
+		return neighbourhood, err
+	}
+	neighbourhood.Center = center
+
+	//walk the labyrinth one level at a time
+	distances := map[string]int{id: 0}
+	frontier := []string{id}
+	for distance := 1; distance <= depth && len(frontier) > 0; distance++ {
+		linked, err := r.getLinkedIds(frontier)
+		if err != nil {
+			return neighbourhood, err
+		}
+		frontier = nil
+		for _, linkedId := range linked {
+			if _, seen := distances[linkedId]; !seen {
+				distances[linkedId] = distance
+				frontier = append(frontier, linkedId)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(distances))
+	for cellId := range distances {
+		ids = append(ids, cellId)
+	}
+	cells, err := r.getCellsById(ids)
+	if err != nil {
+		return neighbourhood, err
+	}
+	for _, cell := range cells {
+		if cell.Id == id {
+			continue
+		}
+		neighbourhood.Cells = append(neighbourhood.Cells, models.Neighbour{Cell: cell, Distance: distances[cell.Id]})
+	}
+	sort.SliceStable(neighbourhood.Cells, func(i, j int) bool {
+		return neighbourhood.Cells[i].Distance < neighbourhood.Cells[j].Distance
+	})
+
+	neighbourhood.Links, err = r.getLinksAmong(ids)
+	if err != nil {
+		return neighbourhood, err
+	}
+	return neighbourhood, nil
+}
+
+//returns the ids of all cells linked to any of the cells passed
+func (r *lobRepository) getLinkedIds(ids []string) ([]string, error) {
+	var linked []string
+	if len(ids) == 0 {
+		return linked, nil
+	}
+	in := placeholders(len(ids))
+	vals := append(stringArgs(ids), stringArgs(ids)...)
+	rows, err := r.getDB().Query(`SELECT c.id FROM cells_links l, cells c
+		WHERE l.cells_b = c.id AND l.cells_a IN (`+in+`)`+r.visible("c")+`
+		UNION
+		SELECT c.id FROM cells_links l, cells c
+		WHERE l.cells_a = c.id AND l.cells_b IN (`+in+`)`+r.visible("c"), vals...)
+	if err != nil {
+		return linked, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var linkedId string
+		err := rows.Scan(&linkedId)
+		if err != nil {
+			return linked, err
+		}
+		linked = append(linked, linkedId)
+	}
+	return linked, rows.Err()
+}
+
+//returns the cells with the ids passed, without their sources or links
+func (r *lobRepository) getCellsById(ids []string) ([]models.Cell, error) {
+	var cells []models.Cell
+	if len(ids) == 0 {
+		return cells, nil
+	}
+	rows, err := r.getDB().Query(`SELECT id, title, body, room, create_time, update_time, `+privacyOf("cells")+` private
+		FROM cells
+		WHERE id IN (`+placeholders(len(ids))+`)`+r.visible("cells")+`
+		ORDER BY create_time DESC`, stringArgs(ids)...)
+	if err != nil {
+		return cells, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cell models.Cell
+		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time, &cell.Private)
+		if err != nil {
+			return cells, err
+		}
+		cells = append(cells, cell)
+	}
+	return cells, rows.Err()
+}
+
+//returns the links whose both ends are among the cells passed
+func (r *lobRepository) getLinksAmong(ids []string) ([]models.Link, error) {
+	var links []models.Link
+	if len(ids) == 0 {
+		return links, nil
+	}
+	in := placeholders(len(ids))
+	vals := append(stringArgs(ids), stringArgs(ids)...)
+	rows, err := r.getDB().Query(`SELECT cells_a, cells_b FROM cells_links
+		WHERE cells_a IN (`+in+`) AND cells_b IN (`+in+`)`, vals...)
+	if err != nil {
+		return links, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link models.Link
+		err := rows.Scan(&link.CellA, &link.CellB)
+		if err != nil {
+			return links, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (r *lobRepository) GetLinksOfCells(ids []string) (map[string][]models.Cell, error) {
+	links := make(map[string][]models.Cell)
+	if len(ids) == 0 {
+		return links, nil
+	}
+	in := placeholders(len(ids))
+	vals := append(stringArgs(ids), stringArgs(ids)...)
+	rows, err := r.getDB().Query(`SELECT l.cells_a linked_to, c.id, c.title, c.body, c.create_time, c.update_time, c.room, `+privacyOf("c")+` private
+		FROM cells_links l, cells c
+		WHERE l.cells_b = c.id
+		AND l.cells_a IN (`+in+`)`+r.visible("c")+`
+		UNION ALL
+		SELECT l.cells_b linked_to, c.id, c.title, c.body, c.create_time, c.update_time, c.room, `+privacyOf("c")+` private
+		FROM cells_links l, cells c
+		WHERE l.cells_a = c.id
+		AND l.cells_b IN (`+in+`)`+r.visible("c")+`
+		ORDER BY create_time DESC`, vals...)
+	if err != nil {
+		return links, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var linkedTo string
+		var cell models.Cell
+		err := rows.Scan(&linkedTo, &cell.Id, &cell.Title, &cell.Body, &cell.Create_time, &cell.Update_time, &cell.Room, &cell.Private)
+		if err != nil {
+			return links, err
+		}
+		links[linkedTo] = append(links[linkedTo], cell)
+	}
+	return links, rows.Err()
+}
+
+func (r *lobRepository) GetSourcesOfCells(ids []string) (map[string][]models.Source, error) {
+	sources := make(map[string][]models.Source)
+	if len(ids) == 0 {
+		return sources, nil
+	}
+	rows, err := r.getDB().Query(`SELECT cells_id, sources_source FROM cells_sources
+		WHERE cells_id IN (`+placeholders(len(ids))+`)
+		ORDER BY sources_source`, stringArgs(ids)...)
+	if err != nil {
+		return sources, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cellId, source string
+		err := rows.Scan(&cellId, &source)
+		if err != nil {
+			return sources, err
+		}
+		sources[cellId] = append(sources[cellId], models.Source{Source: source})
+	}
+	return sources, rows.Err()
+}
+
+func (r *lobRepository) GetTagsOfCells(ids []string) (map[string][]models.Tag, error) {
+	tags := make(map[string][]models.Tag)
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	rows, err := r.getDB().Query(`SELECT cells_id, tags_tag FROM cells_tags
+		WHERE cells_id IN (`+placeholders(len(ids))+`)
+		ORDER BY tags_tag`, stringArgs(ids)...)
+	if err != nil {
+		return tags, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cellId, tag string
+		err := rows.Scan(&cellId, &tag)
+		if err != nil {
+			return tags, err
+		}
+		tags[cellId] = append(tags[cellId], models.Tag{Tag: tag})
+	}
+	return tags, rows.Err()
+}
+
+func (r *lobRepository) ListCellsWithSources(sources []string) (map[string][]models.Cell, error) {
+	cells := make(map[string][]models.Cell)
+	if len(sources) == 0 {
+		return cells, nil
+	}
+	rows, err := r.getDB().Query(`SELECT cs.sources_source, c.id, c.title, c.body, c.room, c.create_time, c.update_time, `+privacyOf("c")+` private
+		FROM cells c, cells_sources cs
+		WHERE cs.cells_id = c.id
+		AND cs.sources_source IN (`+placeholders(len(sources))+`)`+r.visible("c")+`
+		ORDER BY c.update_time DESC`, stringArgs(sources)...)
+	if err != nil {
+		return cells, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var source string
+		var cell models.Cell
+		err := rows.Scan(&source, &cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time, &cell.Private)
+		if err != nil {
+			return cells, err
+		}
+		cells[source] = append(cells[source], cell)
+	}
+	return cells, rows.Err()
+}
+
+func (r *lobRepository) ListCellsInRooms(rooms []string) (map[string][]models.Cell, error) {
+	cells := make(map[string][]models.Cell)
+	if len(rooms) == 0 {
+		return cells, nil
+	}
+	rows, err := r.getDB().Query(`SELECT id, title, body, room, create_time, update_time, `+privacyOf("cells")+` private
+		FROM cells
+		WHERE room IN (`+placeholders(len(rooms))+`)`+r.visible("cells")+`
+		ORDER BY update_time DESC`, stringArgs(rooms)...)
+	if err != nil {
+		return cells, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cell models.Cell
+		err := rows.Scan(&cell.Id, &cell.Title, &cell.Body, &cell.Room, &cell.Create_time, &cell.Update_time, &cell.Private)
+		if err != nil {
+			return cells, err
+		}
+		cells[cell.Room] = append(cells[cell.Room], cell)
+	}
+	return cells, rows.Err()
+}
+
+//returns "?,?,?" with n placeholders to use in IN clauses
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
+
+func (r *lobRepository) SetRoomPrivate(room string, private bool) error {
+	result, err := r.getDB().Exec("UPDATE rooms SET private = ? WHERE room = ?", private, strings.TrimSpace(room))
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		//the room might already be as asked
+		var exists int
+		err = r.getDB().QueryRow("SELECT COUNT(*) FROM rooms WHERE room = ?", strings.TrimSpace(room)).Scan(&exists)
+		if err == nil && exists == 0 {
+			return sql.ErrNoRows
+		}
+		return err
+	}
+	r.audit("set_room_private", strings.TrimSpace(room), map[string]bool{"private": !private}, map[string]bool{"private": private})
+	return nil
+}
+
+func (r *lobRepository) NewUser(user models.User) (string, error) {
+	if strings.TrimSpace(user.Username) == "" {
+		return "", errors.New("Empty username")
+	}
+	if user.PasswordHash == "" {
+		return "", errors.New("Empty password")
+	}
+	if user.Role == models.RoleAnyone {
+		user.Role = models.RoleReader
+	}
+	if _, err := models.ParseRole(string(user.Role)); err != nil {
+		return "", err
+	}
+	userId := uuid.NewString()
+	_, err := r.getDB().Exec("INSERT INTO users(id, username, password_hash, role) VALUES (?, ?, ?, ?)",
+		userId, strings.TrimSpace(user.Username), user.PasswordHash, user.Role)
+	if err != nil {
+		return "", err
+	}
+	r.audit("new_user", userId, nil, map[string]string{"username": strings.TrimSpace(user.Username), "role": string(user.Role)})
+	return userId, nil
+}
+
+func (r *lobRepository) GetUser(id string) (models.User, error) {
+	return r.getUserWhere("id=?", id)
+}
+
+func (r *lobRepository) GetUserByUsername(username string) (models.User, error) {
+	return r.getUserWhere("username=?", strings.TrimSpace(username))
+}
+
+func (r *lobRepository) getUserWhere(condition string, arg string) (models.User, error) {
+	var user models.User
+	row := r.getDB().QueryRow("SELECT id, username, password_hash, role, create_time FROM users WHERE "+condition, arg)
+	err := row.Scan(&user.Id, &user.Username, &user.PasswordHash, &user.Role, &user.Create_time)
+	return user, err
+}
+
+func (r *lobRepository) ListUsers() ([]models.User, error) {
+	var users []models.User
+	rows, err := r.getDB().Query("SELECT id, username, password_hash, role, create_time FROM users ORDER BY username")
+	if err != nil {
+		return users, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var user models.User
+		err = rows.Scan(&user.Id, &user.Username, &user.PasswordHash, &user.Role, &user.Create_time)
+		if err != nil {
+			return users, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *lobRepository) SetUserRole(id string, role models.Role) error {
+	if _, err := models.ParseRole(string(role)); err != nil {
+		return err
+	}
+	before, _ := r.GetUser(id)
+	result, err := r.getDB().Exec("UPDATE users SET role=? WHERE id=?", role, id)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		//the user might already have the role
+		var exists int
+		err = r.getDB().QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", id).Scan(&exists)
+		if err == nil && exists == 0 {
+			return sql.ErrNoRows
+		}
+		return err
+	}
+	r.audit("set_user_role", id, map[string]string{"role": string(before.Role)}, map[string]string{"role": string(role)})
+	return nil
+}
+
+func (r *lobRepository) NewAPIToken(token models.APIToken, hash string) (string, error) {
+	if strings.TrimSpace(token.Name) == "" {
+		return "", errors.New("Empty token name")
+	}
+	if len(token.Scopes) == 0 {
+		return "", errors.New("A token needs at least one scope")
+	}
+	scopes := make([]string, len(token.Scopes))
+	for i, scope := range token.Scopes {
+		if _, err := models.ParseScope(string(scope)); err != nil {
+			return "", err
+		}
+		scopes[i] = string(scope)
+	}
+	tokenId := uuid.NewString()
+	_, err := r.getDB().Exec("INSERT INTO api_tokens(id, users_id, name, token_hash, scopes) VALUES (?, ?, ?, ?, ?)",
+		tokenId, token.UserId, strings.TrimSpace(token.Name), hash, strings.Join(scopes, ","))
+	if err != nil {
+		return "", err
+	}
+	return tokenId, nil
+}
+
+func (r *lobRepository) ListAPITokens(userId string) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	rows, err := r.getDB().Query(`SELECT id, users_id, name, scopes, create_time, last_used_time
+		FROM api_tokens
+		WHERE users_id = ?
+		ORDER BY create_time DESC`, userId)
+	if err != nil {
+		return tokens, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (r *lobRepository) RevokeAPIToken(userId string, id string) error {
+	result, err := r.getDB().Exec("DELETE FROM api_tokens WHERE id = ? AND users_id = ?", id, userId)
+	if err != nil {
+		return err
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *lobRepository) UseAPIToken(hash string) (models.APIToken, error) {
+	row := r.getDB().QueryRow(`SELECT id, users_id, name, scopes, create_time, last_used_time
+		FROM api_tokens
+		WHERE token_hash = ?`, hash)
+	token, err := scanAPIToken(row)
+	if err != nil {
+		return token, err
+	}
+	_, err = r.getDB().Exec("UPDATE api_tokens SET last_used_time = ? WHERE id = ?", time.Now(), token.Id)
+	return token, err
+}
+
+func scanAPIToken(row interface{ Scan(...interface{}) error }) (models.APIToken, error) {
+	var token models.APIToken
+	var scopes string
+	var lastUsed sql.NullTime
+	err := row.Scan(&token.Id, &token.UserId, &token.Name, &scopes, &token.Create_time, &lastUsed)
+	if err != nil {
+		return token, err
+	}
+	for _, scope := range strings.Split(scopes, ",") {
+		token.Scopes = append(token.Scopes, models.Scope(scope))
+	}
+	if lastUsed.Valid {
+		token.Last_used_time = &lastUsed.Time
+	}
+	return token, nil
+}
+
+//the values of a cell recorded in the audit log
+func cellValues(cell models.Cell) map[string]interface{} {
+	sources := []string{}
+	for _, source := range cell.Sources {
+		sources = append(sources, source.Source)
+	}
+	return map[string]interface{}{
+		"title":   cell.Title,
+		"body":    cell.Body,
+		"room":    cell.Room,
+		"private": cell.Private,
+		"sources": sources,
+	}
+}
+
+//the other end of a link recorded in the audit log, found when filtering by cell
+func linkValues(id string) map[string]string {
+	return map[string]string{"cell": id}
+}
+
+//the other end of a link made or removed by a [[wiki link]] in the body of a cell
+func wikiLinkValues(id string) map[string]string {
+	return map[string]string{"cell": id, "by": "wiki link"}
+}
+
+//records a change in the audit log along with the actor making it; the change
+//is already made, so failing to record it is only logged
+func (r *lobRepository) audit(action string, target string, before interface{}, after interface{}) {
+	var userId, username sql.NullString
+	if r.actor != nil {
+		userId = sql.NullString{String: r.actor.Id, Valid: true}
+		username = sql.NullString{String: r.actor.Username, Valid: true}
+	}
+	values := []interface{}{}
+	for _, value := range []interface{}{before, after} {
+		if value == nil {
+			values = append(values, nil)
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			log.Printf("Error when recording %s of %s in the audit log: %s", action, target, err)
+			return
+		}
+		values = append(values, string(encoded))
+	}
+	_, err := r.getDB().Exec("INSERT INTO audit_log(users_id, username, action, target, before_value, after_value) VALUES (?, ?, ?, ?, ?, ?)",
+		userId, username, action, target, values[0], values[1])
+	if err != nil {
+		log.Printf("Error when recording %s of %s in the audit log: %s", action, target, err)
+	}
+}
+
+func (r *lobRepository) ListAudit(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+	query := "SELECT id, users_id, username, action, target, before_value, after_value, create_time FROM audit_log WHERE 1 = 1"
+	args := []interface{}{}
+	if filter.Username != "" {
+		query += " AND username = ?"
+		args = append(args, strings.TrimSpace(filter.Username))
+	}
+	if filter.CellId != "" {
+		query += " AND (target = ? OR before_value->>'$.cell' = ? OR after_value->>'$.cell' = ?)"
+		args = append(args, filter.CellId, filter.CellId, filter.CellId)
+	}
+	if !filter.From.IsZero() {
+		query += " AND create_time >= ?"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += " AND create_time < ?"
+		args = append(args, filter.To)
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+	rows, err := r.getDB().Query(query, args...)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry models.AuditEntry
+		var userId, username, before, after sql.NullString
+		err = rows.Scan(&entry.Id, &userId, &username, &entry.Action, &entry.Target, &before, &after, &entry.Create_time)
+		if err != nil {
+			return entries, err
+		}
+		entry.UserId, entry.Username = userId.String, username.String
+		entry.Before, entry.After = before.String, after.String
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -7,6 +7,7 @@ import (
 	"database/sql"
 	"strings"
 	"io/ioutil"
+	"time"
 
 	"github.com/dacero/labyrinth-of-babel/models"
 	"github.com/dacero/labyrinth-of-babel/repository"
@@ -94,6 +95,87 @@ var _ = Describe("Repository", func() {
 		})
 	})
 	
+	Describe("Retrieving the neighbourhood of a cell", func() {
+		var neighbourhood models.Neighbourhood
+		Context("that exists", func() {
+			BeforeEach(func() {
+				neighbourhood, err = lobRepo.GetNeighbourhood("417ecfe7-d2b4-4e43-afd4-dbf5f431d97d", 2)
+			})
+			It("should return no error", func() {
+				Expect(err).To(BeNil())
+			})
+			It("should contain the cells within reach with their distances", func() {
+				distances := map[string]int{}
+				for _, neighbour := range neighbourhood.Cells {
+					distances[neighbour.Cell.Id] = neighbour.Distance
+				}
+				Expect(distances).To(Equal(map[string]int{
+					"72aed05b-cb2d-4cad-bf70-05d8ae02a7bc": 1,
+					"df38bd04-0ec4-41bf-9e53-d0eeb95a4939": 2}))
+			})
+			It("should contain the links among them", func() {
+				Expect(len(neighbourhood.Links)).To(Equal(2))
+			})
+		})
+		Context("with a depth of zero", func() {
+			BeforeEach(func() {
+				neighbourhood, err = lobRepo.GetNeighbourhood(cellId, 0)
+			})
+			It("should error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+	
+	Describe("Reporting orphan cells", func() {
+		var report models.OrphanReport
+		Context("in all rooms", func() {
+			BeforeEach(func() {
+				report, err = lobRepo.ListOrphanCells("")
+			})
+			It("should return no error", func() {
+				Expect(err).To(BeNil())
+			})
+			It("should find the dead ends", func() {
+				Expect(len(report.NoLinks)).To(Equal(0))
+				Expect(len(report.SingleLink)).To(Equal(2))
+				for _, cell := range report.SingleLink {
+					Expect(cell.Id).ToNot(Equal(cellId))
+				}
+			})
+			It("should not report cells that have sources", func() {
+				Expect(len(report.NoSources)).To(Equal(0))
+			})
+		})
+		Context("in a room without cells", func() {
+			BeforeEach(func() {
+				report, err = lobRepo.ListOrphanCells("This room does not exist")
+			})
+			It("should return an empty report", func() {
+				Expect(err).To(BeNil())
+				Expect(len(report.NoLinks) + len(report.SingleLink) + len(report.NoSources)).To(Equal(0))
+			})
+		})
+	})
+	
+	Describe("Listing the whole labyrinth", func() {
+		It("should return every cell with its sources", func() {
+			cells, err := lobRepo.ListCells()
+			Expect(err).To(BeNil())
+			Expect(len(cells)).To(Equal(3))
+			for _, cell := range cells {
+				if cell.Id == cellId {
+					Expect(len(cell.Sources)).To(Equal(2))
+				}
+			}
+		})
+		It("should return every link", func() {
+			links, err := lobRepo.ListLinks()
+			Expect(err).To(BeNil())
+			Expect(len(links)).To(Equal(2))
+		})
+	})
+	
 	Describe("When I search for cells", func() {
 		Context("given I provide a term only used in one", func() {
 			It("should return only one cell", func() {
@@ -112,6 +194,40 @@ var _ = Describe("Repository", func() {
 		})
 	})
 	
+	Describe("When I use tags", func() {
+		Context("given a cell has tags", func() {
+			It("should return them with the cell", func() {
+				cell, err = lobRepo.GetCell(cellId)
+				Expect(err).To(BeNil())
+				Expect(cell.Tags).To(Equal([]models.Tag{models.Tag{Tag: "Borges"}, models.Tag{Tag: "Ethics"}}))
+			})
+			It("should list the cell with the tag", func() {
+				cells, err := lobRepo.ListCellsWithTag("Borges")
+				Expect(err).To(BeNil())
+				Expect(len(cells)).To(Equal(1))
+				Expect(cells[0].Id).To(Equal(cellId))
+			})
+		})
+		Context("given I search for cells with a tag", func() {
+			It("should only return the cells with all the tags", func() {
+				Expect(len(lobRepo.SearchCellsWithTags("idea", []string{"Ethics"}))).To(Equal(2))
+				Expect(len(lobRepo.SearchCellsWithTags("idea", []string{"Ethics", "Borges"}))).To(Equal(1))
+				Expect(len(lobRepo.SearchCellsWithTags("idea", []string{""}))).To(Equal(3))
+			})
+		})
+		Context("given I add and remove a tag", func() {
+			It("should update the tags of the cell", func() {
+				cell, err = lobRepo.AddTagToCell("417ecfe7-d2b4-4e43-afd4-dbf5f431d97d", models.Tag{Tag: " Labyrinths "})
+				Expect(err).To(BeNil())
+				Expect(cell.Tags).To(Equal([]models.Tag{models.Tag{Tag: "Labyrinths"}}))
+				Expect(len(lobRepo.SearchTags("Labyr"))).To(Equal(1))
+				cell, err = lobRepo.RemoveTagFromCell("417ecfe7-d2b4-4e43-afd4-dbf5f431d97d", models.Tag{Tag: "Labyrinths"})
+				Expect(err).To(BeNil())
+				Expect(len(cell.Tags)).To(Equal(0))
+			})
+		})
+	})
+	
 	Describe("Creating a new cell", func() {
 		Context("with proper information", func() {
 			BeforeEach(func() {
@@ -366,4 +482,410 @@ var _ = Describe("Repository", func() {
 			})
 		})
 	})
+	
+	Describe("When I write wiki links in a cell", func() {
+		var wikiCellId string
+		Context("given they point to existing cells", func() {
+			BeforeEach(func() {
+				wikiCell := models.Cell{Title: "Wiki cell",
+					Body: "Mentions [[Idea two]], [[df38bd04-0ec4-41bf-9e53-d0eeb95a4939|the third idea]] and [[A cell not written yet]]",
+					Room: "This is a room"}
+				wikiCellId, err = lobRepo.NewCell(wikiCell)
+			})
+			It("should link the cells mentioned", func() {
+				Expect(err).To(BeNil())
+				cell, err = lobRepo.GetCell(wikiCellId)
+				Expect(err).To(BeNil())
+				Expect(len(cell.Links)).To(Equal(2))
+			})
+			It("should unlink them when they disappear from the body", func() {
+				Expect(err).To(BeNil())
+				_, err = lobRepo.UpdateCell(models.Cell{Id: wikiCellId,
+					Title: "Wiki cell",
+					Body:  "Only mentions [[Idea two]] now",
+					Room:  "This is a room"})
+				Expect(err).To(BeNil())
+				cell, err = lobRepo.GetCell(wikiCellId)
+				Expect(err).To(BeNil())
+				Expect(len(cell.Links)).To(Equal(1))
+				Expect(cell.Links[0].Id).To(Equal("417ecfe7-d2b4-4e43-afd4-dbf5f431d97d"))
+			})
+		})
+		Context("given I look for a cell by its title", func() {
+			It("should return the cell", func() {
+				cell, err = lobRepo.GetCellByTitle("Idea two")
+				Expect(err).To(BeNil())
+				Expect(cell.Id).To(Equal("417ecfe7-d2b4-4e43-afd4-dbf5f431d97d"))
+			})
+		})
+	})
+	
+	Describe("When I look for unlinked mentions of a cell", func() {
+		var mentionId string
+		BeforeEach(func() {
+			mentionId, err = lobRepo.NewCell(models.Cell{Title: "",
+				Body: "An echo of idea two, still unlinked",
+				Room: "This is a room"})
+			Expect(err).To(BeNil())
+		})
+		It("should return the cells mentioning its title that are not linked", func() {
+			mentions, err := lobRepo.GetUnlinkedMentions("417ecfe7-d2b4-4e43-afd4-dbf5f431d97d")
+			Expect(err).To(BeNil())
+			var ids []string
+			for _, mention := range mentions {
+				Expect(mention.Id).ToNot(Equal("417ecfe7-d2b4-4e43-afd4-dbf5f431d97d"))
+				ids = append(ids, mention.Id)
+			}
+			Expect(ids).To(ContainElement(mentionId))
+		})
+		It("should not return them once linked", func() {
+			err = lobRepo.LinkCells("417ecfe7-d2b4-4e43-afd4-dbf5f431d97d", mentionId)
+			Expect(err).To(BeNil())
+			mentions, err := lobRepo.GetUnlinkedMentions("417ecfe7-d2b4-4e43-afd4-dbf5f431d97d")
+			Expect(err).To(BeNil())
+			for _, mention := range mentions {
+				Expect(mention.Id).ToNot(Equal(mentionId))
+			}
+		})
+		Context("given a title that is part of longer words", func() {
+			var artId, partyId, artisticId string
+			ids := func(cells []models.Cell) []string {
+				var found []string
+				for _, cell := range cells {
+					found = append(found, cell.Id)
+				}
+				return found
+			}
+			BeforeEach(func() {
+				artId, err = lobRepo.NewCell(models.Cell{Title: "Art", Body: "What is made", Room: "This is a room"})
+				Expect(err).To(BeNil())
+				partyId, err = lobRepo.NewCell(models.Cell{Body: "A party about an article", Room: "This is a room"})
+				Expect(err).To(BeNil())
+				artisticId, err = lobRepo.NewCell(models.Cell{Body: "Is this ART, or a craft?", Room: "This is a room"})
+				Expect(err).To(BeNil())
+			})
+			It("should only return the cells mentioning it as a word", func() {
+				mentions, err := lobRepo.GetUnlinkedMentions(artId)
+				Expect(err).To(BeNil())
+				Expect(ids(mentions)).To(ContainElement(artisticId))
+				Expect(ids(mentions)).NotTo(ContainElement(partyId))
+			})
+			It("should return the cells mentioning the labels other cells link it with", func() {
+				_, err = lobRepo.NewCell(models.Cell{Body: "All of [[" + artId + "|the making]] is here", Room: "This is a room"})
+				Expect(err).To(BeNil())
+				makingId, err := lobRepo.NewCell(models.Cell{Body: "The making of the world", Room: "This is a room"})
+				Expect(err).To(BeNil())
+				mentions, err := lobRepo.GetUnlinkedMentions(artId)
+				Expect(err).To(BeNil())
+				Expect(ids(mentions)).To(ContainElement(makingId))
+			})
+		})
+	})
+
+	Describe("When I manage users", func() {
+		It("should find a user by its username", func() {
+			user, err := lobRepo.GetUserByUsername("borges")
+			Expect(err).To(BeNil())
+			Expect(user.Id).To(Equal("0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21"))
+			Expect(user.CheckPassword("labyrinth")).To(BeTrue())
+			Expect(user.CheckPassword("Labyrinth")).To(BeFalse())
+		})
+		It("should create a user with a hashed password", func() {
+			hash, err := models.HashPassword("the garden of forking paths")
+			Expect(err).To(BeNil())
+			id, err := lobRepo.NewUser(models.User{Username: "ts'ui pen", PasswordHash: hash})
+			Expect(err).To(BeNil())
+			user, err := lobRepo.GetUser(id)
+			Expect(err).To(BeNil())
+			Expect(user.Username).To(Equal("ts'ui pen"))
+			Expect(user.Role).To(Equal(models.RoleReader))
+			Expect(user.PasswordHash).NotTo(ContainSubstring("garden"))
+			Expect(user.CheckPassword("the garden of forking paths")).To(BeTrue())
+		})
+		It("should change the role of a user", func() {
+			err := lobRepo.SetUserRole("5d0c7e3b-2a1f-4c8e-b6d9-8f4a1e2c3b70", models.RoleEditor)
+			Expect(err).To(BeNil())
+			user, err := lobRepo.GetUserByUsername("funes")
+			Expect(err).To(BeNil())
+			Expect(user.Role).To(Equal(models.RoleEditor))
+			Expect(lobRepo.SetUserRole(user.Id, models.Role("minotaur"))).NotTo(Succeed())
+			Expect(lobRepo.SetUserRole(user.Id, models.RoleReader)).To(Succeed())
+		})
+		It("should accept the role a user already has, but not a user that does not exist", func() {
+			user, err := lobRepo.GetUserByUsername("funes")
+			Expect(err).To(BeNil())
+			Expect(lobRepo.SetUserRole(user.Id, user.Role)).To(Succeed())
+			Expect(lobRepo.SetUserRole("nobody", models.RoleReader)).To(Equal(sql.ErrNoRows))
+		})
+		It("should list the users by username", func() {
+			users, err := lobRepo.ListUsers()
+			Expect(err).To(BeNil())
+			Expect(len(users)).To(BeNumerically(">=", 3))
+			Expect(users[0].Username).To(Equal("borges"))
+		})
+		It("should not create two users with the same username", func() {
+			_, err := lobRepo.NewUser(models.User{Username: "borges", PasswordHash: "x"})
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("When I make cells private", func() {
+		var (
+			public    repository.LobRepository
+			privateId string
+		)
+		BeforeEach(func() {
+			public = lobRepo.Public()
+			privateId, err = lobRepo.NewCell(models.Cell{Title: "A secret draft",
+				Body:    "Nobody but me should read this draft",
+				Room:    "This is a room",
+				Private: true})
+			Expect(err).To(BeNil())
+			Expect(lobRepo.LinkCells(cellId, privateId)).To(Succeed())
+		})
+		It("should mark them as private", func() {
+			cell, err := lobRepo.GetCell(privateId)
+			Expect(err).To(BeNil())
+			Expect(cell.Private).To(BeTrue())
+		})
+		It("should hide them from the public view", func() {
+			_, err := public.GetCell(privateId)
+			Expect(err).NotTo(BeNil())
+			Expect(public.SearchCells("draft")).To(BeEmpty())
+			Expect(lobRepo.SearchCells("draft")).NotTo(BeEmpty())
+			cells, err := public.ListCellsInRoom("This is a room")
+			Expect(err).To(BeNil())
+			for _, cell := range cells {
+				Expect(cell.Id).NotTo(Equal(privateId))
+			}
+		})
+		It("should hide them from the links of public cells", func() {
+			cell, err := public.GetCell(cellId)
+			Expect(err).To(BeNil())
+			for _, link := range cell.Links {
+				Expect(link.Id).NotTo(Equal(privateId))
+			}
+			links, err := public.ListLinks()
+			Expect(err).To(BeNil())
+			for _, link := range links {
+				Expect(link.CellB).NotTo(Equal(privateId))
+			}
+		})
+		It("should not count them in their room", func() {
+			count := func(lob repository.LobRepository) int {
+				rooms, err := lob.ListRooms()
+				Expect(err).To(BeNil())
+				for _, room := range rooms {
+					if room.Name == "This is a room" {
+						return room.CellCount
+					}
+				}
+				return 0
+			}
+			Expect(count(public)).To(BeNumerically("<", count(lobRepo)))
+		})
+		It("should hide the sources and tags only they have", func() {
+			_, err := lobRepo.AddSourceToCell(privateId, models.Source{Source: "The diary of a secret"})
+			Expect(err).To(BeNil())
+			_, err = lobRepo.AddTagToCell(privateId, models.Tag{Tag: "Secretive"})
+			Expect(err).To(BeNil())
+			Expect(public.SearchSources("diary of a secret")).To(BeEmpty())
+			Expect(lobRepo.SearchSources("diary of a secret")).NotTo(BeEmpty())
+			Expect(public.SearchTags("Secretive")).To(BeEmpty())
+			Expect(lobRepo.SearchTags("Secretive")).NotTo(BeEmpty())
+		})
+	})
+
+	Describe("When I make a room private", func() {
+		BeforeEach(func() {
+			_, err = lobRepo.NewCell(models.Cell{Body: "Dear diary", Room: "Journal"})
+			Expect(err).To(BeNil())
+			Expect(lobRepo.SetRoomPrivate("Journal", true)).To(Succeed())
+		})
+		It("should hide the room and its cells from the public view", func() {
+			Expect(lobRepo.Public().SearchRooms("Journal")).To(BeEmpty())
+			rooms, err := lobRepo.Public().ListRooms()
+			Expect(err).To(BeNil())
+			for _, room := range rooms {
+				Expect(room.Name).NotTo(Equal("Journal"))
+			}
+			cells, err := lobRepo.Public().ListCellsInRoom("Journal")
+			Expect(err).To(BeNil())
+			Expect(cells).To(BeEmpty())
+		})
+		It("should show it as private to users", func() {
+			cells, err := lobRepo.ListCellsInRoom("Journal")
+			Expect(err).To(BeNil())
+			Expect(cells[0].Private).To(BeTrue())
+		})
+		It("should refuse rooms that do not exist", func() {
+			Expect(lobRepo.SetRoomPrivate("Nowhere", true)).NotTo(Succeed())
+		})
+	})
+
+	Describe("When I use API tokens", func() {
+		var (
+			tokenId string
+			hash    string
+		)
+		BeforeEach(func() {
+			_, hash, err = models.GenerateToken()
+			Expect(err).To(BeNil())
+			tokenId, err = lobRepo.NewAPIToken(models.APIToken{UserId: "0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21",
+				Name:   "import script",
+				Scopes: []models.Scope{models.ScopeRead, models.ScopeWrite}}, hash)
+			Expect(err).To(BeNil())
+		})
+		It("should find the token by its hash and record its use", func() {
+			token, err := lobRepo.UseAPIToken(hash)
+			Expect(err).To(BeNil())
+			Expect(token.Id).To(Equal(tokenId))
+			Expect(token.Has(models.ScopeWrite)).To(BeTrue())
+			tokens, err := lobRepo.ListAPITokens("0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21")
+			Expect(err).To(BeNil())
+			Expect(tokens[0].Id).To(Equal(tokenId))
+			Expect(tokens[0].Last_used_time).NotTo(BeNil())
+		})
+		It("should not find it once revoked", func() {
+			Expect(lobRepo.RevokeAPIToken("5d0c7e3b-2a1f-4c8e-b6d9-8f4a1e2c3b70", tokenId)).NotTo(Succeed())
+			Expect(lobRepo.RevokeAPIToken("0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21", tokenId)).To(Succeed())
+			_, err := lobRepo.UseAPIToken(hash)
+			Expect(err).NotTo(BeNil())
+		})
+		It("should refuse tokens without scopes", func() {
+			_, err := lobRepo.NewAPIToken(models.APIToken{UserId: "0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21", Name: "nothing"}, "x")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+	Describe("When I make changes as a user", func() {
+		var (
+			borges = models.User{Id: "0b5a3f0e-6c1d-4f7a-9a43-2f1e8c1d7b21", Username: "borges"}
+			newId  string
+		)
+		BeforeEach(func() {
+			newId, err = lobRepo.WithActor(borges).NewCell(models.Cell{Title: "Tlön", Body: "Uqbar", Room: "This is a room"})
+			Expect(err).To(BeNil())
+		})
+		It("should record who made them, with the values before and after", func() {
+			cell, err := lobRepo.GetCell(newId)
+			Expect(err).To(BeNil())
+			cell.Body = "Orbis Tertius"
+			_, err = lobRepo.WithActor(borges).UpdateCell(cell)
+			Expect(err).To(BeNil())
+			entries, err := lobRepo.ListAudit(models.AuditFilter{CellId: newId})
+			Expect(err).To(BeNil())
+			Expect(len(entries)).To(Equal(2))
+			Expect(entries[0].Action).To(Equal("update_cell"))
+			Expect(entries[0].Username).To(Equal("borges"))
+			Expect(entries[0].Before).To(ContainSubstring("Uqbar"))
+			Expect(entries[0].After).To(ContainSubstring("Orbis Tertius"))
+			Expect(entries[1].Action).To(Equal("new_cell"))
+		})
+		It("should keep the sources of a cell updated without them", func() {
+			_, err = lobRepo.AddSourceToCell(newId, models.Source{Source: "Ficciones"})
+			Expect(err).To(BeNil())
+			_, err = lobRepo.WithActor(borges).UpdateCell(models.Cell{Id: newId, Title: "Tlön", Body: "Orbis Tertius", Room: "This is a room"})
+			Expect(err).To(BeNil())
+			entries, err := lobRepo.ListAudit(models.AuditFilter{CellId: newId, Limit: 1})
+			Expect(err).To(BeNil())
+			Expect(entries[0].Action).To(Equal("update_cell"))
+			Expect(entries[0].After).To(ContainSubstring("Ficciones"))
+		})
+		It("should record the links made and removed by wiki links", func() {
+			_, err = lobRepo.WithActor(borges).UpdateCell(models.Cell{Id: newId, Title: "Tlön", Body: "See [[" + cellId + "|the first cell]]", Room: "This is a room"})
+			Expect(err).To(BeNil())
+			entries, err := lobRepo.ListAudit(models.AuditFilter{CellId: newId, Limit: 1})
+			Expect(err).To(BeNil())
+			Expect(entries[0].Action).To(Equal("link_cells"))
+			Expect(entries[0].After).To(ContainSubstring(cellId))
+			Expect(entries[0].After).To(ContainSubstring("wiki link"))
+			_, err = lobRepo.WithActor(borges).UpdateCell(models.Cell{Id: newId, Title: "Tlön", Body: "Uqbar", Room: "This is a room"})
+			Expect(err).To(BeNil())
+			entries, err = lobRepo.ListAudit(models.AuditFilter{CellId: newId, Limit: 1})
+			Expect(err).To(BeNil())
+			Expect(entries[0].Action).To(Equal("unlink_cells"))
+			Expect(entries[0].Before).To(ContainSubstring(cellId))
+		})
+		It("should find links from either end", func() {
+			Expect(lobRepo.WithActor(borges).LinkCells(cellId, newId)).To(Succeed())
+			entries, err := lobRepo.ListAudit(models.AuditFilter{CellId: newId, Limit: 1})
+			Expect(err).To(BeNil())
+			Expect(entries[0].Action).To(Equal("link_cells"))
+			Expect(entries[0].Target).To(Equal(cellId))
+		})
+		It("should filter by user and date", func() {
+			entries, err := lobRepo.ListAudit(models.AuditFilter{Username: "funes"})
+			Expect(err).To(BeNil())
+			Expect(entries).To(BeEmpty())
+			entries, err = lobRepo.ListAudit(models.AuditFilter{Username: "borges", To: time.Now().AddDate(0, 0, -1)})
+			Expect(err).To(BeNil())
+			Expect(entries).To(BeEmpty())
+			entries, err = lobRepo.ListAudit(models.AuditFilter{Username: "borges", From: time.Now().AddDate(0, 0, -1)})
+			Expect(err).To(BeNil())
+			Expect(entries).NotTo(BeEmpty())
+		})
+		It("should record changes made from the command line without a user", func() {
+			_, err = lobRepo.AddTagToCell(newId, models.Tag{Tag: "Fiction"})
+			Expect(err).To(BeNil())
+			entries, err := lobRepo.ListAudit(models.AuditFilter{CellId: newId, Limit: 1})
+			Expect(err).To(BeNil())
+			Expect(entries[0].Action).To(Equal("add_tag"))
+			Expect(entries[0].Username).To(BeEmpty())
+		})
+	})
+	Describe("When I delete a cell", func() {
+		var doomedId string
+		BeforeEach(func() {
+			doomedId, err = lobRepo.NewCell(models.Cell{Title: "Doomed", Body: "Soon gone", Room: "This is a room",
+				Sources: []models.Source{{Source: "Analects"}}})
+			Expect(err).To(BeNil())
+			Expect(lobRepo.LinkCells(cellId, doomedId)).To(Succeed())
+			_, err = lobRepo.AddTagToCell(doomedId, models.Tag{Tag: "Ephemeral"})
+			Expect(err).To(BeNil())
+			Expect(lobRepo.DeleteCell(doomedId)).To(Succeed())
+		})
+		It("should be gone with its links", func() {
+			_, err := lobRepo.GetCell(doomedId)
+			Expect(err).To(Equal(sql.ErrNoRows))
+			linked, err := lobRepo.CheckLink(cellId, doomedId)
+			Expect(err).To(BeNil())
+			Expect(linked).To(BeFalse())
+		})
+		It("should not delete cells that do not exist", func() {
+			Expect(lobRepo.DeleteCell(doomedId)).To(Equal(sql.ErrNoRows))
+		})
+	})
+	Describe("When I load the cells of a query at once", func() {
+		ideaTwo := "417ecfe7-d2b4-4e43-afd4-dbf5f431d97d"
+		It("should return the links of every cell", func() {
+			links, err := lobRepo.GetLinksOfCells([]string{cellId, ideaTwo})
+			Expect(err).To(BeNil())
+			Expect(len(links[cellId])).To(Equal(2))
+			Expect(links[ideaTwo][0].Id).To(Equal(cellId))
+		})
+		It("should return their sources and tags", func() {
+			sources, err := lobRepo.GetSourcesOfCells([]string{cellId, ideaTwo})
+			Expect(err).To(BeNil())
+			Expect(sources[cellId]).To(Equal([]models.Source{{Source: "Analects"}, {Source: "Confucius"}}))
+			Expect(sources[ideaTwo]).To(Equal([]models.Source{{Source: "Confucius"}}))
+			tags, err := lobRepo.GetTagsOfCells([]string{cellId, ideaTwo})
+			Expect(err).To(BeNil())
+			Expect(tags[cellId]).To(Equal([]models.Tag{{Tag: "Borges"}, {Tag: "Ethics"}}))
+			Expect(tags[ideaTwo]).To(BeEmpty())
+		})
+		It("should return the cells of every source", func() {
+			cells, err := lobRepo.ListCellsWithSources([]string{"Analects", "Confucius"})
+			Expect(err).To(BeNil())
+			Expect(len(cells["Analects"])).To(Equal(1))
+			Expect(len(cells["Confucius"])).To(Equal(3))
+		})
+		It("should return the cells of every room", func() {
+			cells, err := lobRepo.ListCellsInRooms([]string{"This is a room", "Nowhere"})
+			Expect(err).To(BeNil())
+			inRoom, err := lobRepo.ListCellsInRoom("This is a room")
+			Expect(err).To(BeNil())
+			Expect(cells["This is a room"]).To(ConsistOf(inRoom))
+			Expect(cells["Nowhere"]).To(BeEmpty())
+		})
+	})
 })
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dacero/labyrinth-of-babel/handlers"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+//how long sessions last unless configured otherwise
+const (
+	defaultIdleTimeout    = 2 * time.Hour
+	defaultSessionTimeout = 7 * 24 * time.Hour
+)
+
+//builds the store of the sessions and their lifetime from the environment:
+//
+//	SESSION_KEYS            key pairs, newest first, see parseSessionKeys
+//	SESSION_IDLE_TIMEOUT    a duration such as 30m, 0 for no limit
+//	SESSION_TIMEOUT         since logging in, 0 to end sessions with the browser
+//	SESSION_SECURE          true to only send the cookie over HTTPS
+//	SESSION_SAMESITE        lax, strict or none
+func loadSessions() (*sessions.CookieStore, handlers.SessionLifetime, error) {
+	lifetime := handlers.SessionLifetime{Idle: defaultIdleTimeout, Absolute: defaultSessionTimeout}
+	var err error
+	if lifetime.Idle, err = durationFromEnv("SESSION_IDLE_TIMEOUT", lifetime.Idle); err != nil {
+		return nil, lifetime, err
+	}
+	if lifetime.Absolute, err = durationFromEnv("SESSION_TIMEOUT", lifetime.Absolute); err != nil {
+		return nil, lifetime, err
+	}
+
+	keyPairs, err := parseSessionKeys(os.Getenv("SESSION_KEYS"))
+	if err != nil {
+		return nil, lifetime, err
+	}
+	if len(keyPairs) == 0 {
+		log.Print("SESSION_KEYS is not set, everybody will be logged out when the server restarts")
+		keyPairs = [][]byte{securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32)}
+	}
+
+	secure := false
+	if value := os.Getenv("SESSION_SECURE"); value != "" {
+		if secure, err = strconv.ParseBool(value); err != nil {
+			return nil, lifetime, fmt.Errorf("SESSION_SECURE is not true or false: %s", value)
+		}
+	}
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(os.Getenv("SESSION_SAMESITE")) {
+	case "", "lax":
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		if !secure {
+			return nil, lifetime, fmt.Errorf("SESSION_SAMESITE=none needs SESSION_SECURE=true")
+		}
+		sameSite = http.SameSiteNoneMode
+	default:
+		return nil, lifetime, fmt.Errorf("SESSION_SAMESITE is not lax, strict or none: %s", os.Getenv("SESSION_SAMESITE"))
+	}
+
+	store := sessions.NewCookieStore(keyPairs...)
+	//the cookie, and the timestamp signed in it, expire with the session
+	store.MaxAge(int(lifetime.Absolute / time.Second))
+	store.Options.Path = "/"
+	store.Options.HttpOnly = true
+	store.Options.Secure = secure
+	store.Options.SameSite = sameSite
+	return store, lifetime, nil
+}
+
+//reads a duration such as 90m or 12h from the environment
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%s is not a duration such as 30m or 12h: %s", name, value)
+	}
+	return duration, nil
+}
+
+//parses space separated key pairs, each one an authentication key of 32 or
+//64 bytes and an encryption key of 16, 24 or 32 bytes, in base64 and joined
+//by a colon; the first pair signs new cookies, the others are only used to
+//read cookies signed before the keys were rotated
+func parseSessionKeys(value string) ([][]byte, error) {
+	var keyPairs [][]byte
+	for i, pair := range strings.Fields(value) {
+		keys := strings.Split(pair, ":")
+		if len(keys) != 2 {
+			return nil, fmt.Errorf("key pair %d of SESSION_KEYS is not authentication:encryption", i+1)
+		}
+		authentication, err := base64.StdEncoding.DecodeString(keys[0])
+		if err != nil || (len(authentication) != 32 && len(authentication) != 64) {
+			return nil, fmt.Errorf("the authentication key of pair %d of SESSION_KEYS is not 32 or 64 bytes in base64", i+1)
+		}
+		encryption, err := base64.StdEncoding.DecodeString(keys[1])
+		if err != nil || (len(encryption) != 16 && len(encryption) != 24 && len(encryption) != 32) {
+			return nil, fmt.Errorf("the encryption key of pair %d of SESSION_KEYS is not 16, 24 or 32 bytes in base64", i+1)
+		}
+		keyPairs = append(keyPairs, authentication, encryption)
+	}
+	return keyPairs, nil
+}
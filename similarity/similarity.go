@@ -0,0 +1,197 @@
+package similarity
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+)
+
+//words in the title count this many times more than words in the body
+const titleWeight = 2
+
+//words too common to say anything about a cell
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true,
+	"you": true, "all": true, "any": true, "can": true, "had": true, "her": true,
+	"was": true, "one": true, "our": true, "out": true, "has": true, "have": true,
+	"his": true, "how": true, "its": true, "may": true, "new": true, "now": true,
+	"see": true, "two": true, "who": true, "did": true, "get": true, "him": true,
+	"this": true, "that": true, "with": true, "from": true, "they": true, "will": true,
+	"what": true, "when": true, "which": true, "their": true, "there": true, "been": true,
+	"were": true, "than": true, "then": true, "them": true, "these": true, "those": true,
+	"into": true, "only": true, "other": true, "also": true, "some": true, "such": true,
+	"more": true, "most": true, "each": true, "very": true, "just": true, "about": true,
+	"los": true, "las": true, "del": true, "que": true, "por": true, "con": true,
+	"una": true, "para": true, "como": true, "más": true, "pero": true, "sus": true,
+}
+
+//markdown links and images keep their text but lose their urls
+var urlPattern = regexp.MustCompile(`\]\([^)]*\)|https?://\S+`)
+
+//splits a text in lowercase words, without stop words or very short ones
+func Tokenize(text string) []string {
+	text = urlPattern.ReplaceAllString(text, "]")
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	tokens := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) < 3 || stopWords[word] {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+//a cell similar to another one
+type Match struct {
+	Cell  models.Cell
+	Score float64
+}
+
+//TF-IDF vectors of the titles and bodies of a set of cells
+type Index struct {
+	cells   map[string]models.Cell
+	vectors map[string]map[string]float64
+}
+
+func NewIndex(cells []models.Cell) *Index {
+	index := &Index{
+		cells:   make(map[string]models.Cell, len(cells)),
+		vectors: make(map[string]map[string]float64, len(cells)),
+	}
+	frequencies := make(map[string]map[string]float64, len(cells))
+	documents := make(map[string]int)
+	for _, cell := range cells {
+		tokens := Tokenize(cell.Body)
+		for i := 0; i < titleWeight; i++ {
+			tokens = append(tokens, Tokenize(cell.Title)...)
+		}
+		frequency := make(map[string]float64)
+		for _, token := range tokens {
+			frequency[token]++
+		}
+		for token := range frequency {
+			frequency[token] /= float64(len(tokens))
+			documents[token]++
+		}
+		index.cells[cell.Id] = cell
+		frequencies[cell.Id] = frequency
+	}
+
+	n := float64(len(cells))
+	for id, frequency := range frequencies {
+		vector := make(map[string]float64, len(frequency))
+		norm := 0.0
+		for token, tf := range frequency {
+			weight := tf * (math.Log((1+n)/(1+float64(documents[token]))) + 1)
+			vector[token] = weight
+			norm += weight * weight
+		}
+		norm = math.Sqrt(norm)
+		for token := range vector {
+			vector[token] /= norm
+		}
+		index.vectors[id] = vector
+	}
+	return index
+}
+
+//cosine similarity of two cells of the index
+func (index *Index) Similarity(idA string, idB string) float64 {
+	a, b := index.vectors[idA], index.vectors[idB]
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	score := 0.0
+	for token, weight := range a {
+		score += weight * b[token]
+	}
+	return score
+}
+
+//returns up to n cells most similar to the cell, skipping the ids in exclude
+func (index *Index) Similar(id string, n int, exclude map[string]bool) []Match {
+	var matches []Match
+	if _, ok := index.vectors[id]; !ok {
+		return matches
+	}
+	for otherId, cell := range index.cells {
+		if otherId == id || exclude[otherId] {
+			continue
+		}
+		if score := index.Similarity(id, otherId); score > 0 {
+			matches = append(matches, Match{Cell: cell, Score: score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Cell.Id < matches[j].Cell.Id
+	})
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	return matches
+}
+
+//what the engine needs from the repository
+type Loader interface {
+	ListCells() ([]models.Cell, error)
+}
+
+//keeps the index of the labyrinth until cells change
+type Engine struct {
+	loader Loader
+	mutex  sync.Mutex
+	index  *Index
+}
+
+func NewEngine(loader Loader) *Engine {
+	return &Engine{loader: loader}
+}
+
+//returns up to n cells similar to the cell that are not linked to it yet
+func (e *Engine) Suggest(cell models.Cell, n int) ([]models.Cell, error) {
+	var suggestions []models.Cell
+	index, err := e.getIndex()
+	if err != nil {
+		return suggestions, err
+	}
+	linked := make(map[string]bool, len(cell.Links))
+	for _, link := range cell.Links {
+		linked[link.Id] = true
+	}
+	for _, match := range index.Similar(cell.Id, n, linked) {
+		suggestions = append(suggestions, match.Cell)
+	}
+	return suggestions, nil
+}
+
+func (e *Engine) getIndex() (*Index, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.index != nil {
+		return e.index, nil
+	}
+	cells, err := e.loader.ListCells()
+	if err != nil {
+		return nil, err
+	}
+	e.index = NewIndex(cells)
+	return e.index, nil
+}
+
+//discards the index so it is rebuilt on the next suggestion
+func (e *Engine) Invalidate() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.index = nil
+}
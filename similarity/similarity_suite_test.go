@@ -0,0 +1,73 @@
+package similarity_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/similarity"
+)
+
+func TestSimilarity(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Similarity Suite")
+}
+
+var _ = Describe("Similarity", func() {
+	var cells = []models.Cell{
+		models.Cell{Id: "aleph", Title: "The Aleph", Body: "A point in space that contains all other points, seen in a cellar."},
+		models.Cell{Id: "library", Title: "The Library of Babel", Body: "A library of hexagonal galleries containing every possible book."},
+		models.Cell{Id: "books", Title: "Infinite books", Body: "Every possible book exists somewhere in the infinite library."},
+		models.Cell{Id: "ethics", Title: "Virtue", Body: "Confucius taught that virtue is cultivated through ritual."},
+	}
+
+	Describe("Tokenizing a text", func() {
+		It("should drop stop words, short words and urls", func() {
+			Expect(similarity.Tokenize("The [Library](https://example.com/babel) of Babel, and its books")).
+				To(Equal([]string{"library", "babel", "books"}))
+		})
+	})
+
+	Describe("Finding similar cells", func() {
+		var index *similarity.Index
+		BeforeEach(func() {
+			index = similarity.NewIndex(cells)
+		})
+		It("should rank cells sharing rare words first", func() {
+			matches := index.Similar("library", 3, nil)
+			Expect(len(matches)).To(BeNumerically(">", 0))
+			Expect(matches[0].Cell.Id).To(Equal("books"))
+		})
+		It("should skip excluded cells", func() {
+			matches := index.Similar("library", 3, map[string]bool{"books": true})
+			for _, match := range matches {
+				Expect(match.Cell.Id).ToNot(Equal("books"))
+			}
+		})
+		It("should not find anything in common with unrelated cells", func() {
+			Expect(index.Similarity("library", "ethics")).To(BeZero())
+		})
+	})
+
+	Describe("Suggesting links", func() {
+		It("should leave out cells already linked", func() {
+			engine := similarity.NewEngine(cellsLoader(cells))
+			cell := cells[1]
+			cell.Links = []models.Cell{models.Cell{Id: "books"}}
+			suggestions, err := engine.Suggest(cell, 5)
+			Expect(err).To(BeNil())
+			for _, suggestion := range suggestions {
+				Expect(suggestion.Id).ToNot(Equal("books"))
+				Expect(suggestion.Id).ToNot(Equal("library"))
+			}
+		})
+	})
+})
+
+type cellsLoader []models.Cell
+
+func (l cellsLoader) ListCells() ([]models.Cell, error) {
+	return l, nil
+}
@@ -0,0 +1,224 @@
+// Force-directed drawing of the labyrinth, without external libraries so the
+// page works offline. Reads the graph from the element's data-source, links
+// to cells and rooms under its data-base.
+(function () {
+	"use strict";
+
+	var SVG = "http://www.w3.org/2000/svg";
+
+	function svgElement(name, attributes) {
+		var element = document.createElementNS(SVG, name);
+		for (var key in attributes) {
+			element.setAttribute(key, attributes[key]);
+		}
+		return element;
+	}
+
+	// the same room always gets the same colour
+	function roomColour(room) {
+		var hash = 0;
+		for (var i = 0; i < room.length; i++) {
+			hash = (hash * 31 + room.charCodeAt(i)) | 0;
+		}
+		return "hsl(" + (Math.abs(hash) % 360) + ", 55%, 50%)";
+	}
+
+	function radius(node) {
+		return 5 + Math.sqrt(node.degree) * 3;
+	}
+
+	function drawLegend(legend, nodes, base) {
+		var rooms = {};
+		nodes.forEach(function (node) { rooms[node.room] = true; });
+		Object.keys(rooms).sort().forEach(function (room) {
+			var item = document.createElement("li");
+			var swatch = document.createElement("span");
+			swatch.className = "swatch";
+			swatch.style.background = roomColour(room);
+			var link = document.createElement("a");
+			link.href = base + "/room/" + encodeURIComponent(room);
+			link.textContent = room;
+			item.appendChild(swatch);
+			item.appendChild(link);
+			legend.appendChild(item);
+		});
+	}
+
+	function draw(container, data, center, base) {
+		var width = container.clientWidth, height = container.clientHeight;
+		var svg = svgElement("svg", {});
+		var view = svgElement("g", {});
+		svg.appendChild(view);
+		container.appendChild(svg);
+
+		var byId = {};
+		data.nodes.forEach(function (node, i) {
+			var angle = 2 * Math.PI * i / data.nodes.length;
+			node.x = width / 2 + Math.cos(angle) * width / 4;
+			node.y = height / 2 + Math.sin(angle) * height / 4;
+			node.vx = 0;
+			node.vy = 0;
+			byId[node.id] = node;
+		});
+		var links = data.links.map(function (link) {
+			return {source: byId[link.source], target: byId[link.target]};
+		}).filter(function (link) { return link.source && link.target; });
+
+		links.forEach(function (link) {
+			link.element = svgElement("line", {"class": "link"});
+			view.appendChild(link.element);
+		});
+		data.nodes.forEach(function (node) {
+			node.element = svgElement("circle", {
+				"class": node.id === center ? "node center" : "node",
+				r: radius(node),
+				fill: roomColour(node.room)
+			});
+			var title = svgElement("title", {});
+			title.textContent = node.label + " (" + node.room + ")";
+			node.element.appendChild(title);
+			view.appendChild(node.element);
+			if (node.id === center || data.nodes.length <= 30) {
+				node.label_element = svgElement("text", {"class": "label"});
+				node.label_element.textContent = node.label;
+				view.appendChild(node.label_element);
+			}
+		});
+
+		// simulation
+		var alpha = 1, dragged = null, moved = false;
+		function tick() {
+			var nodes = data.nodes;
+			for (var i = 0; i < nodes.length; i++) {
+				for (var j = i + 1; j < nodes.length; j++) {
+					var dx = nodes[j].x - nodes[i].x, dy = nodes[j].y - nodes[i].y;
+					var distance2 = Math.max(dx * dx + dy * dy, 1);
+					var force = 800 * alpha / distance2;
+					nodes[i].vx -= dx * force;
+					nodes[i].vy -= dy * force;
+					nodes[j].vx += dx * force;
+					nodes[j].vy += dy * force;
+				}
+			}
+			links.forEach(function (link) {
+				var dx = link.target.x - link.source.x, dy = link.target.y - link.source.y;
+				var distance = Math.sqrt(dx * dx + dy * dy) || 1;
+				var force = (distance - 60) / distance * 0.05 * alpha;
+				link.source.vx += dx * force;
+				link.source.vy += dy * force;
+				link.target.vx -= dx * force;
+				link.target.vy -= dy * force;
+			});
+			nodes.forEach(function (node) {
+				node.vx += (width / 2 - node.x) * 0.005 * alpha;
+				node.vy += (height / 2 - node.y) * 0.005 * alpha;
+				if (node !== dragged) {
+					node.x += node.vx;
+					node.y += node.vy;
+				}
+				node.vx *= 0.6;
+				node.vy *= 0.6;
+			});
+			render();
+			alpha *= 0.99;
+			if (alpha > 0.005 || dragged) {
+				window.requestAnimationFrame(tick);
+			}
+		}
+
+		function render() {
+			links.forEach(function (link) {
+				link.element.setAttribute("x1", link.source.x);
+				link.element.setAttribute("y1", link.source.y);
+				link.element.setAttribute("x2", link.target.x);
+				link.element.setAttribute("y2", link.target.y);
+			});
+			data.nodes.forEach(function (node) {
+				node.element.setAttribute("cx", node.x);
+				node.element.setAttribute("cy", node.y);
+				if (node.label_element) {
+					node.label_element.setAttribute("x", node.x + radius(node) + 3);
+					node.label_element.setAttribute("y", node.y + 4);
+				}
+			});
+		}
+
+		// panning, zooming, dragging and clicking
+		var zoom = 1, panX = 0, panY = 0, panning = null;
+		function transform() {
+			view.setAttribute("transform", "translate(" + panX + "," + panY + ") scale(" + zoom + ")");
+		}
+		function toGraph(event) {
+			var box = svg.getBoundingClientRect();
+			return {x: (event.clientX - box.left - panX) / zoom, y: (event.clientY - box.top - panY) / zoom};
+		}
+		data.nodes.forEach(function (node) {
+			node.element.addEventListener("mousedown", function (event) {
+				event.stopPropagation();
+				dragged = node;
+				moved = false;
+				if (alpha < 0.1) {
+					alpha = 0.1;
+					window.requestAnimationFrame(tick);
+				}
+			});
+			node.element.addEventListener("click", function () {
+				if (!moved) {
+					window.location.href = base + "/cell/" + encodeURIComponent(node.id);
+				}
+			});
+		});
+		svg.addEventListener("mousedown", function (event) {
+			panning = {x: event.clientX - panX, y: event.clientY - panY};
+		});
+		window.addEventListener("mousemove", function (event) {
+			if (dragged) {
+				var point = toGraph(event);
+				dragged.x = point.x;
+				dragged.y = point.y;
+				moved = true;
+			} else if (panning) {
+				panX = event.clientX - panning.x;
+				panY = event.clientY - panning.y;
+				transform();
+			}
+		});
+		window.addEventListener("mouseup", function () {
+			dragged = null;
+			panning = null;
+		});
+		svg.addEventListener("wheel", function (event) {
+			event.preventDefault();
+			var box = svg.getBoundingClientRect();
+			var x = event.clientX - box.left, y = event.clientY - box.top;
+			var factor = event.deltaY < 0 ? 1.1 : 1 / 1.1;
+			panX = x - (x - panX) * factor;
+			panY = y - (y - panY) * factor;
+			zoom *= factor;
+			transform();
+		});
+
+		window.requestAnimationFrame(tick);
+	}
+
+	document.addEventListener("DOMContentLoaded", function () {
+		var container = document.getElementById("graph");
+		if (!container) {
+			return;
+		}
+		var request = new XMLHttpRequest();
+		request.open("GET", container.getAttribute("data-source"));
+		request.setRequestHeader("Accept", "application/json");
+		request.onload = function () {
+			if (request.status !== 200) {
+				container.textContent = "The labyrinth could not be drawn.";
+				return;
+			}
+			var data = JSON.parse(request.responseText);
+			var base = container.getAttribute("data-base") || "";
+			draw(container, data, container.getAttribute("data-center"), base);
+			drawLegend(document.getElementById("graph-legend"), data.nodes, base);
+		};
+		request.send();
+	});
+})();
@@ -0,0 +1,98 @@
+package vault
+
+import (
+	"archive/zip"
+	"io"
+	"sort"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"gopkg.in/yaml.v2"
+)
+
+//Export writes every cell of the labyrinth as a note of a zipped vault,
+//room/title.md, with its links as [[wikilinks]]
+func Export(lob repository.LobRepository, w io.Writer) error {
+	cells, err := lob.ListCells()
+	if err != nil {
+		return err
+	}
+	ids := make([]string, len(cells))
+	for i, cell := range cells {
+		ids[i] = cell.Id
+	}
+	tags, err := lob.GetTagsOfCells(ids)
+	if err != nil {
+		return err
+	}
+	links, err := lob.ListLinks()
+	if err != nil {
+		return err
+	}
+
+	names := noteNames(cells)
+	linked := make(map[string][]string)
+	for _, link := range links {
+		linked[link.CellA] = append(linked[link.CellA], "[["+names[link.CellB]+"]]")
+		linked[link.CellB] = append(linked[link.CellB], "[["+names[link.CellA]+"]]")
+	}
+	//wiki links by title go to the oldest cell with it, as in the labyrinth
+	byTitle := make(map[string]string)
+	for _, cell := range cells {
+		if _, ok := byTitle[cell.Title]; !ok {
+			byTitle[cell.Title] = cell.Id
+		}
+	}
+
+	archive := zip.NewWriter(w)
+	for _, cell := range cells {
+		matter := FrontMatter{Id: cell.Id,
+			Title:   cell.Title,
+			Room:    cell.Room,
+			Private: cell.Private,
+			Sources: []string{},
+			Links:   linked[cell.Id],
+			Created: cell.Create_time.UTC(),
+			Updated: cell.Update_time.UTC()}
+		for _, source := range cell.Sources {
+			matter.Sources = append(matter.Sources, source.Source)
+		}
+		for _, tag := range tags[cell.Id] {
+			matter.Tags = append(matter.Tags, tag.Tag)
+		}
+		sort.Strings(matter.Links)
+		front, err := yaml.Marshal(matter)
+		if err != nil {
+			return err
+		}
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: roomFolder(cell.Room) + "/" + names[cell.Id] + ".md",
+			Method:   zip.Deflate,
+			Modified: cell.Update_time})
+		if err != nil {
+			return err
+		}
+		body := models.ReplaceWikiLinks(cell.Body, func(link models.WikiLink) string {
+			return vaultLink(link, names, byTitle)
+		})
+		if _, err := io.WriteString(file, "---\n"+string(front)+"---\n"+body+"\n"); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+//the wiki link as it reads in the vault, to the note of the cell it links
+func vaultLink(link models.WikiLink, names map[string]string, byTitle map[string]string) string {
+	id := link.Target
+	if !link.ById {
+		id = byTitle[link.Target]
+	}
+	name, ok := names[id]
+	switch {
+	case !ok && link.ById:
+		return "[[" + link.Target + "|" + link.Label + "]]"
+	case !ok || name == link.Label:
+		return "[[" + link.Label + "]]"
+	}
+	return "[[" + name + "|" + link.Label + "]]"
+}
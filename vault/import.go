@@ -0,0 +1,491 @@
+package vault
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"gopkg.in/yaml.v2"
+)
+
+//notes outside any folder and without a room in their front matter go to this room
+const defaultRoom = "Imported"
+
+//a Markdown note of a vault, with its path within it
+type File struct {
+	Path    string
+	Content []byte
+}
+
+//Read returns the notes of the vault at the path, a folder or a zip
+func Read(vaultPath string) ([]File, error) {
+	info, err := os.Stat(vaultPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return ReadFolder(vaultPath)
+	}
+	file, err := os.Open(vaultPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadZip(file, info.Size())
+}
+
+//ReadFolder returns the notes of the vault in the folder, leaving out hidden
+//folders such as .obsidian
+func ReadFolder(dir string) ([]File, error) {
+	var files []File
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath != dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !isNote(filePath) {
+			return nil
+		}
+		content, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: filepath.ToSlash(relative), Content: content})
+		return nil
+	})
+	return files, err
+}
+
+//ReadZip returns the notes of a zipped vault
+func ReadZip(r io.ReaderAt, size int64) ([]File, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !isNote(entry.Name) || hidden(entry.Name) {
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: entry.Name, Content: content})
+	}
+	return files, nil
+}
+
+func isNote(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".md")
+}
+
+//whether a path of a zip is within a hidden folder or is a hidden file
+func hidden(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+//what an import did, or would do in a dry run, by the paths of the notes
+type Report struct {
+	Created   []string
+	Updated   []string
+	Unchanged []string
+	//notes without an id that update the cell with their title, and its id
+	ByTitle []string
+	//notes that cannot be cells, with the reason
+	Skipped []string
+	//how many links between cells the import makes
+	Links int
+	//wikilinks to notes that are neither in the vault nor in the labyrinth
+	Unresolved []string
+}
+
+//a note as a cell, and the cell it creates or updates
+type note struct {
+	path string
+	//the name of the note, which wikilinks use
+	name  string
+	cell  models.Cell
+	tags  []string
+	links []models.WikiLink
+	//the cell of the labyrinth it updates, nil when it creates one
+	existing *models.Cell
+	//whether the front matter tells the room and the privacy, the cell it
+	//updates keeps its own when it does not
+	hasRoom, hasPrivate bool
+}
+
+//Import creates a cell for each note of the vault, or updates the cell it
+//was exported from, found by the id in its front matter or, for notes
+//without an id, by its title, so importing a vault again changes nothing;
+//folders are the rooms of new cells unless the front matter tells the room,
+//and wikilinks to other notes link their cells. The room and privacy of a
+//cell only change when the front matter tells them, and sources and tags
+//are added, never removed. A dry run only reports what the import would do.
+func Import(lob repository.LobRepository, files []File, dryRun bool) (Report, error) {
+	var report Report
+	cells, err := lob.ListCells()
+	if err != nil {
+		return report, err
+	}
+	ids := make([]string, len(cells))
+	byId := make(map[string]*models.Cell, len(cells))
+	byTitle := make(map[string][]string)
+	for i := range cells {
+		ids[i] = cells[i].Id
+		byId[cells[i].Id] = &cells[i]
+		byTitle[cells[i].Title] = append(byTitle[cells[i].Title], cells[i].Id)
+	}
+	tags, err := lob.GetTagsOfCells(ids)
+	if err != nil {
+		return report, err
+	}
+
+	var notes []*note
+	claimed := make(map[string]bool)
+	for _, file := range files {
+		n, err := parseNote(file)
+		if err != nil {
+			report.Skipped = append(report.Skipped, file.Path+": "+err.Error())
+			continue
+		}
+		if id := n.cell.Id; id != "" {
+			if byId[id] != nil && !claimed[id] {
+				n.existing = byId[id]
+			}
+		} else {
+			for _, id := range byTitle[n.cell.Title] {
+				if !claimed[id] {
+					n.existing = byId[id]
+					report.ByTitle = append(report.ByTitle, n.path+": "+id)
+					break
+				}
+			}
+		}
+		n.cell.Id = ""
+		if n.existing != nil {
+			claimed[n.existing.Id] = true
+			n.cell.Id = n.existing.Id
+			if !n.hasRoom {
+				n.cell.Room = n.existing.Room
+			}
+			if !n.hasPrivate {
+				n.cell.Private = n.existing.Private
+			}
+		}
+		notes = append(notes, n)
+	}
+
+	//wikilinks find notes by name, or cells of the labyrinth by title or id
+	byName := make(map[string]*note, len(notes))
+	firstWithTitle := make(map[string]*note)
+	for _, n := range notes {
+		if _, ok := byName[strings.ToLower(n.name)]; !ok {
+			byName[strings.ToLower(n.name)] = n
+		}
+		if _, ok := firstWithTitle[n.cell.Title]; !ok {
+			firstWithTitle[n.cell.Title] = n
+		}
+	}
+	resolve := func(link models.WikiLink) (*note, string) {
+		target := link.Target
+		if i := strings.Index(target, "#"); i >= 0 {
+			target = target[:i]
+		}
+		target = strings.TrimSuffix(path.Base(strings.TrimSpace(target)), ".md")
+		if n, ok := byName[strings.ToLower(target)]; ok {
+			return n, n.cell.Id
+		}
+		if found := byTitle[link.Target]; len(found) > 0 {
+			return nil, found[0]
+		}
+		if byId[link.Target] != nil {
+			return nil, link.Target
+		}
+		return nil, ""
+	}
+	//whether [[title]] leads to the cell, as it leads to the oldest one with it
+	ownsTitle := func(title string, target *note, targetId string) bool {
+		if found := byTitle[title]; len(found) > 0 {
+			return found[0] == targetId
+		}
+		return target != nil && firstWithTitle[title] == target
+	}
+
+	//the links the import makes, between cells not linked yet
+	type pair struct{ a, b *note }
+	var pairs []pair
+	seen := make(map[[2]string]bool)
+	for _, n := range notes {
+		for _, link := range append(n.cell.WikiLinks(), n.links...) {
+			target, targetId := resolve(link)
+			if target == nil && targetId == "" {
+				report.Unresolved = append(report.Unresolved, n.path+": [["+link.Target+"]]")
+				continue
+			}
+			if target == n || targetId != "" && targetId == n.cell.Id {
+				continue
+			}
+			if target == nil {
+				target = &note{path: targetId, cell: models.Cell{Id: targetId}, existing: byId[targetId]}
+			}
+			key := [2]string{n.path, target.path}
+			if key[0] > key[1] {
+				key[0], key[1] = key[1], key[0]
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if n.existing != nil && target.existing != nil {
+				linked, err := lob.CheckLink(n.existing.Id, target.existing.Id)
+				if err != nil {
+					return report, err
+				}
+				if linked {
+					continue
+				}
+			}
+			pairs = append(pairs, pair{n, target})
+		}
+	}
+	report.Links = len(pairs)
+
+	//creates the new cells first, so wikilinks to them can be written by id
+	for _, n := range notes {
+		if n.existing != nil {
+			continue
+		}
+		report.Created = append(report.Created, n.path)
+		if dryRun {
+			continue
+		}
+		id, err := lob.NewCell(n.cell)
+		if err != nil {
+			return report, fmt.Errorf("%s: %s", n.path, err)
+		}
+		n.cell.Id = id
+		for _, tag := range n.tags {
+			if _, err := lob.AddTagToCell(id, models.Tag{Tag: tag}); err != nil {
+				return report, fmt.Errorf("%s: %s", n.path, err)
+			}
+		}
+	}
+
+	for _, n := range notes {
+		stored := n.cell.Body
+		n.cell.Body = models.ReplaceWikiLinks(n.cell.Body, func(link models.WikiLink) string {
+			target, targetId := resolve(link)
+			switch {
+			case targetId == "":
+				return labyrinthLink(link)
+			case link.Label == titleOf(target, byId[targetId]) && ownsTitle(link.Label, target, targetId):
+				return "[[" + link.Label + "]]"
+			}
+			return "[[" + targetId + "|" + link.Label + "]]"
+		})
+		if n.existing == nil {
+			if !dryRun && n.cell.Body != stored {
+				if _, err := lob.UpdateCell(n.cell); err != nil {
+					return report, fmt.Errorf("%s: %s", n.path, err)
+				}
+			}
+			continue
+		}
+		changed, err := update(lob, n, tags[n.existing.Id], dryRun)
+		if err != nil {
+			return report, fmt.Errorf("%s: %s", n.path, err)
+		}
+		if changed {
+			report.Updated = append(report.Updated, n.path)
+		} else {
+			report.Unchanged = append(report.Unchanged, n.path)
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	for _, p := range pairs {
+		linked, err := lob.CheckLink(p.a.cell.Id, p.b.cell.Id)
+		if err != nil {
+			return report, err
+		}
+		if linked {
+			//linked by the wikilinks of the body when it was written
+			continue
+		}
+		if err := lob.LinkCells(p.a.cell.Id, p.b.cell.Id); err != nil {
+			return report, fmt.Errorf("%s: %s", p.a.path, err)
+		}
+	}
+	return report, nil
+}
+
+//the title of the cell a wikilink leads to, a note or a cell of the labyrinth
+func titleOf(target *note, cell *models.Cell) string {
+	if target != nil {
+		return target.cell.Title
+	}
+	if cell != nil {
+		return cell.Title
+	}
+	return ""
+}
+
+//a wikilink to nothing the import knows, kept as the labyrinth would read it
+func labyrinthLink(link models.WikiLink) string {
+	if link.Label == link.Target {
+		return "[[" + link.Target + "]]"
+	}
+	return "[[" + link.Target + "|" + link.Label + "]]"
+}
+
+//updates the cell of the labyrinth with what the note changes of it, and
+//returns whether it changes anything
+func update(lob repository.LobRepository, n *note, tags []models.Tag, dryRun bool) (bool, error) {
+	current := n.existing
+	changed := current.Title != n.cell.Title || strings.TrimSpace(current.Body) != n.cell.Body ||
+		current.Room != n.cell.Room || current.Private != n.cell.Private
+	if changed && !dryRun {
+		if _, err := lob.UpdateCell(n.cell); err != nil {
+			return changed, err
+		}
+	}
+	has := make(map[string]bool)
+	for _, source := range current.Sources {
+		has["source "+source.Source] = true
+	}
+	for _, tag := range tags {
+		has["tag "+tag.Tag] = true
+	}
+	for _, source := range n.cell.Sources {
+		if has["source "+source.Source] {
+			continue
+		}
+		changed = true
+		if dryRun {
+			continue
+		}
+		if _, err := lob.AddSourceToCell(current.Id, source); err != nil {
+			return changed, err
+		}
+	}
+	for _, tag := range n.tags {
+		if has["tag "+tag] {
+			continue
+		}
+		changed = true
+		if dryRun {
+			continue
+		}
+		if _, err := lob.AddTagToCell(current.Id, models.Tag{Tag: tag}); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+//reads the note, its front matter when it has one and its folder as its room
+//when the front matter does not tell it
+func parseNote(file File) (*note, error) {
+	content := strings.ReplaceAll(string(file.Content), "\r\n", "\n")
+	matter := map[string]interface{}{}
+	if strings.HasPrefix(content, "---\n") {
+		end := strings.Index(content[len("---\n"):], "\n---")
+		if end < 0 {
+			return nil, fmt.Errorf("The front matter does not end")
+		}
+		front := content[len("---\n") : len("---\n")+end]
+		content = strings.TrimPrefix(content[len("---\n")+end+len("\n---"):], "\n")
+		if err := yaml.Unmarshal([]byte(front), &matter); err != nil {
+			return nil, fmt.Errorf("The front matter is not valid YAML: %s", err)
+		}
+	}
+
+	name := strings.TrimSuffix(path.Base(file.Path), path.Ext(file.Path))
+	n := &note{path: file.Path, name: name}
+	n.cell.Id = text(matter["id"])
+	n.cell.Title = text(matter["title"])
+	if _, ok := matter["title"]; !ok {
+		n.cell.Title = name
+	}
+	n.cell.Room = text(matter["room"])
+	n.hasRoom = n.cell.Room != ""
+	if !n.hasRoom {
+		n.cell.Room = defaultRoom
+		if folder := path.Base(path.Dir(file.Path)); folder != "." && folder != "/" {
+			n.cell.Room = folder
+		}
+	}
+	n.cell.Private, n.hasPrivate = matter["private"].(bool)
+	n.cell.Body = strings.TrimSpace(content)
+	if n.cell.Body == "" {
+		return nil, fmt.Errorf("The note is empty")
+	}
+	for _, source := range texts(matter["sources"]) {
+		n.cell.Sources = append(n.cell.Sources, models.Source{Source: source})
+	}
+	for _, tag := range texts(matter["tags"]) {
+		n.tags = append(n.tags, strings.TrimPrefix(tag, "#"))
+	}
+	links := models.Cell{Body: strings.Join(texts(matter["links"]), " ")}
+	n.links = links.WikiLinks()
+	return n, nil
+}
+
+//a value of the front matter as text
+func text(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+//a value of the front matter as a list of texts, which may be written as a
+//YAML list or separated by commas
+func texts(value interface{}) []string {
+	var values []string
+	switch v := value.(type) {
+	case nil:
+	case []interface{}:
+		for _, item := range v {
+			if t := text(item); t != "" {
+				values = append(values, t)
+			}
+		}
+	default:
+		for _, item := range strings.Split(text(v), ",") {
+			if t := strings.TrimSpace(item); t != "" {
+				values = append(values, t)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,77 @@
+//Package vault writes the labyrinth as a vault of Markdown notes, one per
+//cell in a folder per room, as Obsidian and other editors keep them
+package vault
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+)
+
+//the YAML front matter of a note, what the labyrinth knows of a cell besides its body
+type FrontMatter struct {
+	Id      string   `yaml:"id"`
+	Title   string   `yaml:"title"`
+	Room    string   `yaml:"room"`
+	Private bool     `yaml:"private,omitempty"`
+	Sources []string `yaml:"sources"`
+	Tags    []string `yaml:"tags,omitempty"`
+	//the notes of the linked cells, as [[wikilinks]]
+	Links   []string  `yaml:"links,omitempty"`
+	Created time.Time `yaml:"created"`
+	Updated time.Time `yaml:"updated"`
+}
+
+//notes are named after their cells, without what file systems or wikilinks refuse
+var unsafeName = regexp.MustCompile(`[\\/:*?"<>|#^\[\]\x00-\x1f]+`)
+
+//names longer than this are cut
+const maxNameLength = 100
+
+//returns the text as a file name, empty if nothing of it is left
+func fileName(text string) string {
+	name := strings.Join(strings.Fields(unsafeName.ReplaceAllString(text, " ")), " ")
+	name = strings.TrimLeft(name, ".")
+	if utf8.RuneCountInString(name) > maxNameLength {
+		name = strings.TrimSpace(string([]rune(name)[:maxNameLength]))
+	}
+	return name
+}
+
+//the folder of the notes of a room
+func roomFolder(room string) string {
+	if folder := fileName(room); folder != "" {
+		return folder
+	}
+	return "No room"
+}
+
+//names the note of every cell after its title, or its id when it has none;
+//wikilinks find notes by name in the whole vault, so a cell whose name is
+//taken by an older one gets the start of its id after it
+func noteNames(cells []models.Cell) map[string]string {
+	names := make(map[string]string, len(cells))
+	taken := make(map[string]bool, len(cells))
+	for _, cell := range cells {
+		name := fileName(cell.Title)
+		if name == "" {
+			name = cell.Id
+		}
+		if taken[strings.ToLower(name)] {
+			name += " (" + shortId(cell.Id) + ")"
+		}
+		taken[strings.ToLower(name)] = true
+		names[cell.Id] = name
+	}
+	return names
+}
+
+func shortId(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
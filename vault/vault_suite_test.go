@@ -0,0 +1,327 @@
+package vault_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/dacero/labyrinth-of-babel/models"
+	"github.com/dacero/labyrinth-of-babel/repository"
+	"github.com/dacero/labyrinth-of-babel/vault"
+	"gopkg.in/yaml.v2"
+)
+
+func TestVault(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Vault Suite")
+}
+
+//splits a note into its front matter and its body
+func readNote(note string) (vault.FrontMatter, string) {
+	var matter vault.FrontMatter
+	Expect(note).To(HavePrefix("---\n"))
+	parts := strings.SplitN(note[len("---\n"):], "\n---\n", 2)
+	Expect(parts).To(HaveLen(2))
+	Expect(yaml.Unmarshal([]byte(parts[0]), &matter)).To(Succeed())
+	return matter, parts[1]
+}
+
+var _ = Describe("Exporting a vault", func() {
+	var (
+		lob   *memoryRepository
+		notes map[string]string
+	)
+	created := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		lob = &memoryRepository{cells: []models.Cell{
+			{Id: "aleph-1111", Title: "The Aleph", Body: "See [[Funes]] and [[tlon-3333|Uqbar]]", Room: "Borges",
+				Sources: []models.Source{{Source: "El Aleph"}}, Create_time: created, Update_time: created},
+			{Id: "funes-2222", Title: "Funes", Body: "Remembers everything", Room: "Borges", Create_time: created, Update_time: created},
+			{Id: "tlon-3333", Title: "Tlön: Uqbar", Body: "Orbis Tertius", Room: "Fictions/Ficciones", Private: true, Create_time: created, Update_time: created},
+			{Id: "funes-4444", Title: "Funes", Body: "Another Funes", Room: "Borges", Create_time: created, Update_time: created},
+		},
+			tags:  map[string][]models.Tag{"aleph-1111": {{Tag: "Infinity"}}},
+			links: []models.Link{{CellA: "aleph-1111", CellB: "funes-2222"}, {CellA: "tlon-3333", CellB: "aleph-1111"}},
+		}
+		var zipped bytes.Buffer
+		Expect(vault.Export(lob, &zipped)).To(Succeed())
+		archive, err := zip.NewReader(bytes.NewReader(zipped.Bytes()), int64(zipped.Len()))
+		Expect(err).To(BeNil())
+		notes = make(map[string]string)
+		for _, file := range archive.File {
+			reader, err := file.Open()
+			Expect(err).To(BeNil())
+			content, err := ioutil.ReadAll(reader)
+			Expect(err).To(BeNil())
+			notes[file.Name] = string(content)
+		}
+	})
+
+	It("should write a note per cell in the folder of its room", func() {
+		Expect(notes).To(HaveLen(4))
+		Expect(notes).To(HaveKey("Borges/The Aleph.md"))
+		Expect(notes).To(HaveKey("Fictions Ficciones/Tlön Uqbar.md"))
+	})
+	It("should keep what the labyrinth knows of the cell in its front matter", func() {
+		matter, body := readNote(notes["Borges/The Aleph.md"])
+		Expect(matter.Id).To(Equal("aleph-1111"))
+		Expect(matter.Room).To(Equal("Borges"))
+		Expect(matter.Sources).To(Equal([]string{"El Aleph"}))
+		Expect(matter.Tags).To(Equal([]string{"Infinity"}))
+		Expect(matter.Created.Equal(created)).To(BeTrue())
+		Expect(body).To(HavePrefix("See "))
+		private, _ := readNote(notes["Fictions Ficciones/Tlön Uqbar.md"])
+		Expect(private.Private).To(BeTrue())
+	})
+	It("should write links as wikilinks to the notes", func() {
+		matter, body := readNote(notes["Borges/The Aleph.md"])
+		Expect(matter.Links).To(Equal([]string{"[[Funes]]", "[[Tlön Uqbar]]"}))
+		Expect(body).To(Equal("See [[Funes]] and [[Tlön Uqbar|Uqbar]]\n"))
+	})
+	It("should tell apart cells with the same title", func() {
+		matter, _ := readNote(notes["Borges/Funes (funes-44).md"])
+		Expect(matter.Id).To(Equal("funes-4444"))
+	})
+})
+
+var _ = Describe("Importing a vault", func() {
+	var lob *memoryRepository
+	created := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	//writes the notes as a vault in a temporary folder and reads it back
+	readFolder := func(notes map[string]string) []vault.File {
+		dir, err := ioutil.TempDir("", "vault")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		for name, content := range notes {
+			file := filepath.Join(dir, filepath.FromSlash(name))
+			Expect(os.MkdirAll(filepath.Dir(file), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(file, []byte(content), 0644)).To(Succeed())
+		}
+		files, err := vault.Read(dir)
+		Expect(err).To(BeNil())
+		return files
+	}
+	notes := map[string]string{
+		"Borges/Funes.md": "Remembers everything\n",
+		"Ideas/Library.md": "---\nroom: Fictions\nsources: [Ficciones]\ntags: ['#infinite']\n---\n" +
+			"Contains [[Funes|the memorious]] and [[Missing]]\n",
+		".obsidian/workspace.md": "not a note",
+		"Empty.md":               "---\ntitle: Nothing\n---\n",
+	}
+
+	BeforeEach(func() {
+		lob = &memoryRepository{tags: map[string][]models.Tag{}}
+	})
+
+	It("should only report what it would do in a dry run", func() {
+		report, err := vault.Import(lob, readFolder(notes), true)
+		Expect(err).To(BeNil())
+		Expect(report.Created).To(ConsistOf("Borges/Funes.md", "Ideas/Library.md"))
+		Expect(report.Skipped).To(ConsistOf(HavePrefix("Empty.md")))
+		Expect(report.Unresolved).To(ConsistOf("Ideas/Library.md: [[Missing]]"))
+		Expect(report.Links).To(Equal(1))
+		Expect(lob.cells).To(BeEmpty())
+		Expect(lob.writes).To(Equal(0))
+	})
+	It("should create a cell for each note, in its room, with its sources, tags and links", func() {
+		_, err := vault.Import(lob, readFolder(notes), false)
+		Expect(err).To(BeNil())
+		Expect(lob.cells).To(HaveLen(2))
+		funes, library := lob.byTitle("Funes"), lob.byTitle("Library")
+		Expect(funes.Room).To(Equal("Borges"))
+		Expect(library.Room).To(Equal("Fictions"))
+		Expect(library.Sources).To(Equal([]models.Source{{Source: "Ficciones"}}))
+		Expect(lob.tags[library.Id]).To(Equal([]models.Tag{{Tag: "infinite"}}))
+		Expect(library.Body).To(Equal("Contains [[" + funes.Id + "|the memorious]] and [[Missing]]"))
+		Expect(lob.links).To(Equal([]models.Link{{CellA: library.Id, CellB: funes.Id}}))
+	})
+	It("should change nothing when importing the same vault again", func() {
+		_, err := vault.Import(lob, readFolder(notes), false)
+		Expect(err).To(BeNil())
+		writes := lob.writes
+		report, err := vault.Import(lob, readFolder(notes), false)
+		Expect(err).To(BeNil())
+		Expect(report.Created).To(BeEmpty())
+		Expect(report.Unchanged).To(HaveLen(2))
+		Expect(report.Links).To(Equal(0))
+		Expect(lob.cells).To(HaveLen(2))
+		Expect(lob.writes).To(Equal(writes))
+	})
+
+	Context("exported from the labyrinth", func() {
+		var files []vault.File
+
+		BeforeEach(func() {
+			lob.cells = []models.Cell{
+				{Id: "aleph-1111", Title: "The Aleph", Body: "See [[Funes]] and [[tlon-3333|Uqbar]]", Room: "Borges",
+					Sources: []models.Source{{Source: "El Aleph"}}, Create_time: created},
+				{Id: "funes-2222", Title: "Funes", Body: "Remembers everything", Room: "Borges", Create_time: created},
+				{Id: "tlon-3333", Title: "Tlön: Uqbar", Body: "Orbis Tertius", Room: "Fictions", Private: true, Create_time: created},
+				{Id: "funes-4444", Title: "Funes", Body: "Another Funes", Room: "Borges", Create_time: created},
+			}
+			lob.tags["aleph-1111"] = []models.Tag{{Tag: "Infinity"}}
+			lob.links = []models.Link{{CellA: "aleph-1111", CellB: "funes-2222"}, {CellA: "aleph-1111", CellB: "tlon-3333"}}
+			var zipped bytes.Buffer
+			Expect(vault.Export(lob, &zipped)).To(Succeed())
+			var err error
+			files, err = vault.ReadZip(bytes.NewReader(zipped.Bytes()), int64(zipped.Len()))
+			Expect(err).To(BeNil())
+			Expect(files).To(HaveLen(4))
+		})
+
+		It("should find every cell again and change nothing", func() {
+			report, err := vault.Import(lob, files, false)
+			Expect(err).To(BeNil())
+			Expect(report.Unchanged).To(HaveLen(4))
+			Expect(report.Links).To(Equal(0))
+			Expect(report.Unresolved).To(BeEmpty())
+			Expect(lob.writes).To(Equal(0))
+		})
+		It("should update the cells whose notes changed", func() {
+			for i := range files {
+				if files[i].Path == "Fictions/Tlön Uqbar.md" {
+					files[i].Content = bytes.Replace(files[i].Content, []byte("Orbis Tertius"), []byte("Orbis Tertius, see [[Funes (funes-44)]]"), 1)
+				}
+			}
+			report, err := vault.Import(lob, files, false)
+			Expect(err).To(BeNil())
+			Expect(report.Updated).To(Equal([]string{"Fictions/Tlön Uqbar.md"}))
+			Expect(lob.cells).To(HaveLen(4))
+			Expect(lob.cells[2].Body).To(Equal("Orbis Tertius, see [[funes-4444|Funes (funes-44)]]"))
+			Expect(lob.links).To(ContainElement(models.Link{CellA: "tlon-3333", CellB: "funes-4444"}))
+		})
+		It("should keep the room and privacy the front matter does not tell", func() {
+			for i := range files {
+				if files[i].Path == "Fictions/Tlön Uqbar.md" {
+					files[i].Path = "Elsewhere/Tlön Uqbar.md"
+					files[i].Content = bytes.Replace(files[i].Content, []byte("room: Fictions\n"), nil, 1)
+					files[i].Content = bytes.Replace(files[i].Content, []byte("private: true\n"), nil, 1)
+					files[i].Content = bytes.Replace(files[i].Content, []byte("Orbis Tertius"), []byte("Orbis Tertius Uqbar"), 1)
+				}
+			}
+			report, err := vault.Import(lob, files, false)
+			Expect(err).To(BeNil())
+			Expect(report.Updated).To(Equal([]string{"Elsewhere/Tlön Uqbar.md"}))
+			Expect(lob.cells[2].Body).To(Equal("Orbis Tertius Uqbar"))
+			Expect(lob.cells[2].Room).To(Equal("Fictions"))
+			Expect(lob.cells[2].Private).To(BeTrue())
+		})
+		It("should only find cells by their title for notes without an id", func() {
+			for i := range files {
+				switch files[i].Path {
+				case "Borges/The Aleph.md":
+					files[i].Content = bytes.Replace(files[i].Content, []byte("id: aleph-1111\n"), nil, 1)
+				case "Borges/Funes.md":
+					files[i].Content = bytes.Replace(files[i].Content, []byte("id: funes-2222\n"), []byte("id: gone-9999\n"), 1)
+				}
+			}
+			report, err := vault.Import(lob, files, true)
+			Expect(err).To(BeNil())
+			Expect(report.ByTitle).To(Equal([]string{"Borges/The Aleph.md: aleph-1111"}))
+			Expect(report.Created).To(Equal([]string{"Borges/Funes.md"}))
+			Expect(report.Unchanged).To(ContainElement("Borges/The Aleph.md"))
+			Expect(lob.writes).To(Equal(0))
+		})
+	})
+})
+
+//a labyrinth kept in memory, counting the changes made to it
+type memoryRepository struct {
+	repository.LobRepository
+	cells  []models.Cell
+	tags   map[string][]models.Tag
+	links  []models.Link
+	writes int
+}
+
+func (m *memoryRepository) ListCells() ([]models.Cell, error) {
+	return append([]models.Cell{}, m.cells...), nil
+}
+
+func (m *memoryRepository) GetTagsOfCells(ids []string) (map[string][]models.Tag, error) {
+	return m.tags, nil
+}
+
+func (m *memoryRepository) ListLinks() ([]models.Link, error) {
+	return m.links, nil
+}
+
+func (m *memoryRepository) byTitle(title string) models.Cell {
+	for _, cell := range m.cells {
+		if cell.Title == title {
+			return cell
+		}
+	}
+	Fail("No cell titled " + title)
+	return models.Cell{}
+}
+
+func (m *memoryRepository) cell(id string) *models.Cell {
+	for i := range m.cells {
+		if m.cells[i].Id == id {
+			return &m.cells[i]
+		}
+	}
+	return nil
+}
+
+func (m *memoryRepository) NewCell(cell models.Cell) (string, error) {
+	m.writes++
+	cell.Id = fmt.Sprintf("new-%d", len(m.cells))
+	m.cells = append(m.cells, cell)
+	return cell.Id, nil
+}
+
+func (m *memoryRepository) UpdateCell(cell models.Cell) (int64, error) {
+	m.writes++
+	current := m.cell(cell.Id)
+	if current == nil {
+		return 0, errors.New("Not found")
+	}
+	cell.Sources = current.Sources
+	*current = cell
+	return 1, nil
+}
+
+func (m *memoryRepository) AddSourceToCell(id string, source models.Source) (models.Cell, error) {
+	m.writes++
+	current := m.cell(id)
+	current.Sources = append(current.Sources, source)
+	return *current, nil
+}
+
+func (m *memoryRepository) AddTagToCell(id string, tag models.Tag) (models.Cell, error) {
+	m.writes++
+	m.tags[id] = append(m.tags[id], tag)
+	return *m.cell(id), nil
+}
+
+func (m *memoryRepository) CheckLink(a string, b string) (bool, error) {
+	for _, link := range m.links {
+		if link.CellA == a && link.CellB == b || link.CellA == b && link.CellB == a {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryRepository) LinkCells(a string, b string) error {
+	m.writes++
+	if linked, _ := m.CheckLink(a, b); linked {
+		return errors.New("Tried linking cells already linked")
+	}
+	m.links = append(m.links, models.Link{CellA: a, CellB: b})
+	return nil
+}